@@ -2,6 +2,8 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
@@ -48,6 +50,9 @@ func subBenchmarks(b *testing.B, src, tgt, tgtUnordered []byte) {
 		{"equivalent", makeopts(Equivalent()), tgt},
 		{"equivalent-unordered", makeopts(Equivalent()), tgtUnordered},
 		{"factor+ratio", makeopts(Factorize(), Rationalize()), tgt},
+		{"lcs", makeopts(LCS()), tgt},
+		{"lcs-unordered", makeopts(LCS()), tgtUnordered},
+		{"lcs+factorize", makeopts(LCS(), Factorize()), tgt},
 		{"all", makeopts(Factorize(), Rationalize(), Invertible(), Equivalent()), tgt},
 		{"all-unordered", makeopts(Factorize(), Rationalize(), Invertible(), Equivalent()), tgtUnordered},
 	} {
@@ -118,6 +123,108 @@ func subBenchmarks(b *testing.B, src, tgt, tgtUnordered []byte) {
 	}
 }
 
+func BenchmarkLCS(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000, 20000} {
+		// The target slice is a copy of the source
+		// slice with about 1% of elements changed.
+		r := rand.New(rand.NewSource(int64(size)))
+		src := randomSlice(r, size, size)
+		tgt := make([]interface{}, len(src))
+		copy(tgt, src)
+
+		for i := 0; i < max(1, size/100); i++ {
+			tgt[r.Intn(size)] = float64(-1)
+		}
+		b.Run(fmt.Sprintf("similar-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = lcs(src, tgt, &hasher{})
+			}
+		})
+	}
+	for _, size := range []int{100, 1000} {
+		// Arrays of objects with a few fields,
+		// with about 10% of elements changed.
+		r := rand.New(rand.NewSource(int64(size)))
+		src := make([]interface{}, size)
+		tgt := make([]interface{}, size)
+
+		for i := range src {
+			obj := map[string]interface{}{
+				"id":   float64(i),
+				"name": fmt.Sprintf("element %d", i),
+				"tags": []interface{}{"a", "b", "c"},
+			}
+			src[i], tgt[i] = obj, obj
+		}
+		for i := 0; i < size/10; i++ {
+			tgt[r.Intn(size)] = map[string]interface{}{"id": float64(-1)}
+		}
+		b.Run(fmt.Sprintf("objects-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = lcs(src, tgt, &hasher{})
+			}
+		})
+	}
+	for _, size := range []int{100, 1000} {
+		r := rand.New(rand.NewSource(int64(size)))
+		src := randomSlice(r, size, 10)
+		tgt := randomSlice(r, size, 10)
+
+		b.Run(fmt.Sprintf("random-%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_ = lcs(src, tgt, &hasher{})
+			}
+		})
+	}
+}
+
+func BenchmarkCompareReflect(b *testing.B) {
+	type item struct {
+		ID     int               `json:"id"`
+		Name   string            `json:"name"`
+		Tags   []string          `json:"tags,omitempty"`
+		Labels map[string]string `json:"labels"`
+		Score  float64           `json:"score,string"`
+	}
+	type document struct {
+		Items []item `json:"items"`
+	}
+	var src, tgt document
+	for i := 0; i < 1000; i++ {
+		it := item{
+			ID:     i,
+			Name:   fmt.Sprintf("item %d", i),
+			Tags:   []string{"a", "b"},
+			Labels: map[string]string{"k": "v"},
+			Score:  float64(i) / 3,
+		}
+		src.Items = append(src.Items, it)
+		if i%10 == 0 {
+			it.Name = "changed"
+		}
+		tgt.Items = append(tgt.Items, it)
+	}
+	b.Run("Compare", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := Compare(src, tgt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("CompareReflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := CompareReflect(src, tgt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func compactBytes(src []byte) []byte {
 	b := make([]byte, 0, len(src))
 	copy(b, src)
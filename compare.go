@@ -1,8 +1,11 @@
 package jsondiff
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 // Compare compares the JSON representations of the
@@ -25,13 +28,69 @@ func CompareJSON(source, target []byte, opts ...Option) (Patch, error) {
 	return compareJSON(&d, source, target, d.opts.unmarshal)
 }
 
-// CompareWithoutMarshal is similar to Compare, but it assumes
-// that the given interface values consists only of primitives
-// Go types that are recognized by the json.Unmarshal function,
-// and therefore does not marshal/unmarshal before comparison.
-func CompareWithoutMarshal(source, target interface{}, opts ...Option) (patch Patch, err error) {
+// CompareContext is similar to Compare, but the comparison
+// is interrupted if the context is done before it completes,
+// in which case the error of the context is returned.
+func CompareContext(ctx context.Context, source, target interface{}, opts ...Option) (Patch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d := Differ{ctx: ctx}
+	d.applyOpts(opts...)
+
+	return compare(&d, source, target)
+}
+
+// CompareJSONContext is similar to CompareJSON, but the
+// comparison is interrupted if the context is done before
+// it completes, in which case the error of the context is
+// returned.
+func CompareJSONContext(ctx context.Context, source, target []byte, opts ...Option) (Patch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	d := Differ{ctx: ctx}
+	d.applyOpts(opts...)
+
+	return compareJSON(&d, source, target, d.opts.unmarshal)
+}
+
+// CompareReflect is similar to Compare, but it converts the
+// given values to their JSON representation by reflection,
+// instead of marshaling and unmarshaling them. The conversion
+// follows the rules of the encoding/json package, including
+// struct tags options and embedded structs, and produce the
+// same values that json.Unmarshal would decode, therefore the
+// MarshalFunc and UnmarshalFunc options have no effect.
+func CompareReflect(source, target interface{}, opts ...Option) (Patch, error) {
 	var d Differ
+	d.applyOpts(opts...)
 
+	return compareReflect(&d, source, target)
+}
+
+// CompareWithoutMarshal is similar to Compare, but it does not
+// marshal/unmarshal the given values before comparison. Instead,
+// they are normalized to the types recognized by json.Unmarshal,
+// which means that they may consist of any Go scalar, slice, array
+// and map types, including the map[interface{}]interface{} type
+// produced by YAML decoders. Values of other types, such as structs,
+// are not supported, in which case the returned error names the
+// JSON Pointer of the value. Values that are already of the types
+// produced by json.Unmarshal are used without copy.
+func CompareWithoutMarshal(source, target interface{}, opts ...Option) (patch Patch, err error) {
+	var (
+		d Differ
+		e engine.Encoder
+	)
+	si, err := e.Normalize(source)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := e.Normalize(target)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		if r := recover(); r != nil {
 			e := r.(invalidJSONTypeError)
@@ -40,7 +99,10 @@ func CompareWithoutMarshal(source, target interface{}, opts ...Option) (patch Pa
 		}
 	}()
 	d.applyOpts(opts...)
-	d.Compare(source, target)
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
 	patch = d.patch
 
 	return patch, err
@@ -64,6 +126,37 @@ func compare(d *Differ, src, tgt interface{}) (Patch, error) {
 	d.targetBytes = tb
 
 	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.patch, nil
+}
+
+func compareReflect(d *Differ, src, tgt interface{}) (Patch, error) {
+	var e engine.Encoder
+
+	si, err := e.Encode(src)
+	if err != nil {
+		return nil, err
+	}
+	ti, err := e.Encode(tgt)
+	if err != nil {
+		return nil, err
+	}
+	if d.opts.rationalize {
+		// The rationalization of operations compares
+		// their length with the one of the target.
+		tb, err := json.Marshal(ti)
+		if err != nil {
+			return nil, err
+		}
+		d.targetBytes = tb
+		d.isCompact = true
+	}
+	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
 	return d.patch, nil
 }
 
@@ -81,6 +174,9 @@ func compareJSON(d *Differ, src, tgt []byte, unmarshal unmarshalFunc) (Patch, er
 	d.targetBytes = tgt
 
 	d.Compare(si, ti)
+	if d.err != nil {
+		return nil, d.err
+	}
 	return d.patch, nil
 }
 
@@ -2,9 +2,14 @@ package jsondiff
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_marshalUnmarshal_invalid_JSON(t *testing.T) {
@@ -89,3 +94,337 @@ func Test_compareJSON_marshaling_error(t *testing.T) {
 		t.Errorf("expected non-nil error")
 	}
 }
+
+func TestCompareContext(t *testing.T) {
+	src := map[string]interface{}{"a": []interface{}{1, 2, 3}}
+	tgt := map[string]interface{}{"a": []interface{}{1, 3}}
+
+	patch, err := CompareContext(context.Background(), src, tgt, LCS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 {
+		t.Errorf("got %d operations, want 1", len(patch))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := CompareContext(ctx, src, tgt); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if _, err := CompareJSONContext(ctx, []byte(`[1]`), []byte(`[2]`)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDiffer_stopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	src := randomSlice(rand.New(rand.NewSource(1)), 500, 8)
+	tgt := randomSlice(rand.New(rand.NewSource(2)), 500, 8)
+
+	// The comparison is interrupted while the
+	// LCS of the arrays is computed.
+	d := Differ{ctx: ctx}
+	d.applyOpts(LCS())
+	d.compareArraysLCS(d.ptr, src, tgt, "")
+
+	if !errors.Is(d.err, context.Canceled) {
+		t.Errorf("got error %v, want %v", d.err, context.Canceled)
+	}
+	if len(d.patch) != 0 {
+		t.Errorf("got %d operations, want none", len(d.patch))
+	}
+}
+
+func TestCompareJSON_limits(t *testing.T) {
+	const (
+		src = `{"_":1,"a":{"b":{"c":1,"d":2}},"e":[1,2,3]}`
+		tgt = `{"_":2,"a":{"b":{"c":2,"d":3}},"e":[4,5,6]}`
+	)
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		patch []string
+	}{
+		{
+			"no limits",
+			nil,
+			[]string{"replace /_", "replace /a/b/c", "replace /a/b/d", "replace /e/0", "replace /e/1", "replace /e/2"},
+		},
+		{
+			"max depth",
+			[]Option{MaxDepth(1)},
+			[]string{"replace /_", "replace /a", "replace /e"},
+		},
+		{
+			"max depth of nested object",
+			[]Option{MaxDepth(2)},
+			[]string{"replace /_", "replace /a/b", "replace /e/0", "replace /e/1", "replace /e/2"},
+		},
+		{
+			"max operations",
+			[]Option{MaxOperations(5)},
+			[]string{"replace /_", "replace /a/b/c", "replace /a/b/d", "replace /e"},
+		},
+		{
+			"max operations collapsed to the root",
+			[]Option{MaxOperations(3)},
+			[]string{"add "},
+		},
+		{
+			"max operations lower than the root replacement",
+			[]Option{MaxOperations(1), Invertible()},
+			[]string{"add "},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := CompareJSON([]byte(src), []byte(tgt), tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops := make([]string, 0, len(patch))
+			for _, op := range patch {
+				ops = append(ops, op.Type+" "+op.Path)
+			}
+			if !reflect.DeepEqual(ops, tc.patch) {
+				t.Errorf("got %v, want %v", ops, tc.patch)
+			}
+			// The patch must still transform the
+			// source document into the target.
+			b, err := applyPatchJSON([]byte(src), patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !json.Valid(b) {
+				t.Fatalf("invalid patched document: %s", b)
+			}
+			var got, want interface{}
+			_ = json.Unmarshal(b, &got)
+			_ = json.Unmarshal([]byte(tgt), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got patched document %s, want %s", b, tgt)
+			}
+		})
+	}
+}
+
+func TestCompareJSON_limitsFactorize(t *testing.T) {
+	// The removal of /a, which precedes the operations
+	// of /b, is replaced by a move into /b, which must
+	// be undone when these operations are collapsed.
+	const (
+		src = `{"a":"v","b":{"c":1,"d":2,"e":3},"z":"a value that is long enough to not replace the document"}`
+		tgt = `{"b":{"c":10,"d":20,"e":30,"f":"v"},"z":"a value that is long enough to not replace the document"}`
+	)
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		patch []string
+	}{
+		{
+			"factorize",
+			[]Option{Factorize()},
+			[]string{"replace /b/c", "replace /b/d", "replace /b/e", "move /b/f"},
+		},
+		{
+			"max operations",
+			[]Option{Factorize(), MaxOperations(3)},
+			[]string{"remove /a", "replace /b"},
+		},
+		{
+			"max operations invertible",
+			[]Option{Factorize(), Invertible(), MaxOperations(4)},
+			[]string{"test /a", "remove /a", "test /b", "replace /b"},
+		},
+		{
+			"rationalize",
+			[]Option{Factorize(), Rationalize()},
+			[]string{"remove /a", "replace /b"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := CompareJSON([]byte(src), []byte(tgt), tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops := make([]string, 0, len(patch))
+			for _, op := range patch {
+				ops = append(ops, op.Type+" "+op.Path)
+			}
+			if !reflect.DeepEqual(ops, tc.patch) {
+				t.Errorf("got %v, want %v", ops, tc.patch)
+			}
+			b, err := applyPatchJSON([]byte(src), patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			_ = json.Unmarshal(b, &got)
+			_ = json.Unmarshal([]byte(tgt), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got patched document %s, want %s", b, tgt)
+			}
+		})
+	}
+}
+
+func TestCompareReflect(t *testing.T) {
+	type tagged struct {
+		Renamed string            `json:"renamed"`
+		Int     int               `json:",string"`
+		Any     interface{}       `json:"any"`
+		Bytes   []byte            `json:"bytes"`
+		StrMap  map[string]string `json:"strMap"`
+		Time    time.Time         `json:"time"`
+	}
+	src := tagged{
+		Renamed: "a",
+		Int:     1,
+		Any:     map[string]interface{}{"x": []int{1, 2, 3}},
+		Bytes:   []byte("foo"),
+		StrMap:  map[string]string{"a": "1", "b": "2"},
+		Time:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tgt := tagged{
+		Renamed: "b",
+		Int:     2,
+		Any:     map[string]interface{}{"x": []int{3, 1, 2}, "y": "new"},
+		Bytes:   []byte("bar"),
+		StrMap:  map[string]string{"b": "2", "c": "1"},
+		Time:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Factorize()},
+		{Rationalize()},
+		{Invertible(), LCS()},
+		{Equivalent(), Factorize(), Rationalize()},
+	} {
+		want, err := Compare(src, tgt, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CompareReflect(src, tgt, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("got patch:\n%s\nwant:\n%s", got, want)
+		}
+	}
+	if _, err := CompareReflect(src, struct{ C chan int }{}); err == nil {
+		t.Errorf("expected non-nil error")
+	}
+}
+
+func TestCompareWithoutMarshal_normalize(t *testing.T) {
+	src := map[interface{}]interface{}{
+		"name":     "app",
+		"replicas": 1,
+		"ports":    []int{80, 443},
+	}
+	tgt := map[string]interface{}{
+		"name":     "app",
+		"replicas": 2.0,
+		"ports":    []interface{}{80.0, 443.0, 8080.0},
+	}
+	patch, err := CompareWithoutMarshal(src, tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"value":8080,"op":"add","path":"/ports/-"},{"value":2,"op":"replace","path":"/replicas"}]`
+	if b, _ := json.Marshal(patch); string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestCompareWithoutMarshal_largeIntegers(t *testing.T) {
+	for _, tc := range []struct {
+		src, tgt any
+		want     string
+	}{
+		{int64(9007199254740993), int64(9007199254740992), `[{"value":9007199254740992,"op":"replace","path":""}]`},
+		{int64(9007199254740993), int64(9007199254740994), `[{"value":9007199254740994,"op":"replace","path":""}]`},
+		{uint64(9007199254740995), uint(9007199254740995), `null`},
+		{int64(-9007199254740993), int64(-9007199254740994), `[{"value":-9007199254740994,"op":"replace","path":""}]`},
+	} {
+		patch, err := CompareWithoutMarshal(
+			map[string]any{"id": tc.src},
+			map[string]any{"id": tc.tgt},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.ReplaceAll(tc.want, `"path":""`, `"path":"/id"`)
+		if b, _ := json.Marshal(patch); string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	}
+}
+
+func TestCompareJSON_jsonNumber(t *testing.T) {
+	useNumber := UnmarshalFunc(func(b []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		return dec.Decode(v)
+	})
+	for _, tc := range []struct {
+		name  string
+		src   string
+		tgt   string
+		opts  []Option
+		patch []string
+	}{
+		{
+			"numeric value",
+			`{"a":1.0,"id":9007199254740993}`,
+			`{"a":1,"id":9007199254740992}`,
+			nil,
+			[]string{"replace /id"},
+		},
+		{
+			"equivalent",
+			`[1,2,3]`,
+			`[3,2,1]`,
+			[]Option{Equivalent()},
+			nil,
+		},
+		{
+			"equivalent with different values",
+			`[1,2,3]`,
+			`[3,2,4]`,
+			[]Option{Equivalent()},
+			[]string{"replace /0", "replace /2"},
+		},
+		{
+			"factorize",
+			`{"a":{"b":1},"c":[1,2]}`,
+			`{"a":{"b":1.0},"d":[1,2],"e":{"b":1}}`,
+			[]Option{Factorize()},
+			[]string{"move /d", "copy /e"},
+		},
+		{
+			"float tolerance",
+			`{"a":0.30000000000000004}`,
+			`{"a":0.3}`,
+			[]Option{FloatTolerance(1e-9, 0)},
+			nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := CompareJSON([]byte(tc.src), []byte(tc.tgt), append(tc.opts, useNumber)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops := make([]string, 0, len(patch))
+			for _, op := range patch {
+				ops = append(ops, op.Type+" "+op.Path)
+			}
+			if len(ops) != len(tc.patch) || (len(ops) != 0 && !reflect.DeepEqual(ops, tc.patch)) {
+				t.Errorf("got %v, want %v", ops, tc.patch)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package jsondiff
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// Compose returns a patch equivalent to the application of the
+// patch p1, followed by the application of the patch p2, which
+// means that if p1 transforms a document A into a document B,
+// and p2 transforms B into a document C, the returned patch
+// transforms A into C. The intermediate document B is not
+// required.
+//
+// The operations of the patches that refer to the same location,
+// or to nested locations, are merged together when the other
+// operations applied in-between do not depend on them: an add
+// followed by a remove cancels out, successive replacements are
+// folded into a single replace, a remove followed by an add
+// becomes a replace, the operations applied to the children of
+// an added or replaced value are applied to that value, and the
+// operations applied to the children of a value that is later
+// replaced or removed are dropped. A test operation that checks
+// a value set by a previous operation is dropped, and the test
+// operations of the patches are otherwise preserved. Move and
+// copy operations are never merged.
+//
+// The patches are expected to be sequential. Like the patches
+// generated by the Compare functions, an add operation whose
+// path refers to an object member is assumed to create that
+// member. If the operations of p2 cannot be applied to the
+// values set by p1, an error of type *PatchError is returned,
+// whose Index is the position of the operation in the
+// concatenation of p1 and p2.
+func Compose(p1, p2 Patch) (Patch, error) {
+	var c composer
+
+	for i, op := range slices.Concat(p1, p2) {
+		if err := c.add(op); err != nil {
+			return nil, patchError(err, i)
+		}
+	}
+	return c.patch(), nil
+}
+
+// A composer composes the operations of
+// successive patches into a single patch.
+type composer struct {
+	a   engine.Applier
+	ops []composedOp
+}
+
+// A composedOp is an operation of a patch being
+// composed, along with its parsed pointers.
+type composedOp struct {
+	Operation
+	path engine.ParsedPointer
+	from engine.ParsedPointer
+
+	// replaces reports whether the path of a move
+	// refers to an existing object member.
+	replaces bool
+}
+
+// A location is a pointer read or written by
+// an operation.
+type location struct {
+	ptr   engine.ParsedPointer
+	write bool
+	shift bool // array insertion or deletion
+}
+
+func (c *composer) patch() Patch {
+	if len(c.ops) == 0 {
+		return nil
+	}
+	p := make(Patch, len(c.ops))
+	for i, op := range c.ops {
+		p[i] = op.Operation
+	}
+	return p
+}
+
+// add merges the operation with the operations already
+// composed when possible, or appends it otherwise.
+func (c *composer) add(op Operation) *engine.Error {
+	x := composedOp{Operation: op}
+
+	var err *engine.Error
+	if x.path, err = engine.ParsePath(op.Path); err != nil {
+		return err
+	}
+	if op.hasFrom() {
+		if x.from, err = engine.ParsePath(op.From); err != nil {
+			return err
+		}
+	}
+	switch op.Type {
+	case OperationAdd, OperationRemove, OperationReplace, OperationTest:
+	default:
+		c.ops = append(c.ops, x)
+		return nil
+	}
+	for i := len(c.ops) - 1; i >= 0; i-- {
+		prev := &c.ops[i]
+		if prev.independent(x) {
+			continue
+		}
+		var (
+			merged bool
+			drop   bool
+		)
+		switch {
+		case slices.Equal(prev.path.Tokens, x.path.Tokens):
+			merged, err = c.mergeSame(i, x)
+		case isPrefix(prev.path, x.path):
+			merged, err = c.mergeChild(prev, x)
+		case isPrefix(x.path, prev.path):
+			drop = x.overwrites() && prev.isOverwritten()
+		}
+		if err != nil {
+			return err
+		}
+		if merged {
+			return nil
+		}
+		if !drop {
+			break
+		}
+		// The value written by the previous operation
+		// is overwritten by the operation, whose old
+		// value is therefore unknown.
+		c.ops = slices.Delete(c.ops, i, i+1)
+		x.OldValue = nil
+		x.withOld = false
+	}
+	c.ops = append(c.ops, x)
+
+	return nil
+}
+
+// mergeSame merges the operation x with the operation
+// at position i, that refers to the same location, and
+// returns whether it succeeded.
+func (c *composer) mergeSame(i int, x composedOp) (bool, *engine.Error) {
+	prev := &c.ops[i]
+
+	if isAppend(prev.path) {
+		return false, nil
+	}
+	switch prev.Type {
+	case OperationAdd, OperationReplace:
+		switch x.Type {
+		case OperationRemove:
+			if prev.Type == OperationAdd {
+				// The added value is removed.
+				c.ops = slices.Delete(c.ops, i, i+1)
+			} else {
+				prev.Type = OperationRemove
+				prev.Value = nil
+				prev.valueLen = 0
+			}
+			return true, nil
+		case OperationAdd:
+			if isIndex(x.path) {
+				// Insertion of another array element.
+				return false, nil
+			}
+			fallthrough
+		case OperationReplace:
+			prev.Value = x.Value
+			prev.valueLen = x.valueLen
+			prev.absent = x.absent
+			return true, nil
+		case OperationTest:
+			op := x.Operation
+			op.Path = emptyPointer
+			_, err := c.applyTo(prev.Value, op, x.Path)
+			return err == nil, err
+		}
+	case OperationRemove:
+		if x.Type == OperationAdd {
+			prev.Type = OperationReplace
+			prev.Value = x.Value
+			prev.valueLen = x.valueLen
+			prev.absent = x.absent
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mergeChild applies the operation x, that refers to a
+// descendant of the location of the operation prev, to
+// the value set by prev, and returns whether it succeeded.
+func (c *composer) mergeChild(prev *composedOp, x composedOp) (bool, *engine.Error) {
+	if prev.Type != OperationAdd && prev.Type != OperationReplace || isAppend(prev.path) {
+		return false, nil
+	}
+	// Apply the operation relatively to the value.
+	rel := x.Operation
+	rel.Path = x.Path[len(prev.Path):]
+
+	v, err := c.applyTo(prev.Value, rel, prev.Path)
+	if err != nil {
+		return false, err
+	}
+	if x.Type != OperationTest {
+		prev.Value = v
+		prev.valueLen = 0
+		prev.absent &^= absentValue
+	}
+	return true, nil
+}
+
+// applyTo applies the operation op to a copy of the
+// value v, located at the pointer ptr of the document,
+// and returns the result.
+func (c *composer) applyTo(v any, op Operation, ptr string) (any, *engine.Error) {
+	doc, err := c.a.Normalize(v)
+	if err != nil {
+		return nil, &engine.Error{
+			Err:     fmt.Errorf("%w: %s", ErrInvalidOperation, err),
+			Pointer: ptr,
+		}
+	}
+	doc = engine.DeepCopy(doc)
+
+	if err := c.a.ApplyOp(&doc, op.operation()); err != nil {
+		err.Pointer = ptr + err.Pointer
+		return nil, err
+	}
+	return doc, nil
+}
+
+// independent returns whether the operations x and
+// y can be applied in any order.
+func (x composedOp) independent(y composedOp) bool {
+	for _, l1 := range x.locations() {
+		for _, l2 := range y.locations() {
+			if l1.interferes(l2) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// locations returns the locations read or written
+// by the operation.
+func (x composedOp) locations() []location {
+	switch x.Type {
+	case OperationAdd, OperationRemove:
+		return []location{{ptr: x.path, write: true, shift: isIndex(x.path)}}
+	case OperationReplace:
+		return []location{{ptr: x.path, write: true}}
+	case OperationTest:
+		return []location{{ptr: x.path}}
+	case OperationMove:
+		return []location{
+			{ptr: x.from, write: true, shift: isIndex(x.from)},
+			{ptr: x.path, write: true, shift: isIndex(x.path)},
+		}
+	case OperationCopy:
+		return []location{
+			{ptr: x.from},
+			{ptr: x.path, write: true, shift: isIndex(x.path)},
+		}
+	}
+	// Unknown operations depend on everything.
+	return []location{{ptr: engine.ParsedPointer{}, write: true}}
+}
+
+// isOverwritten returns whether the operation has
+// no effect if the value at its path is overwritten.
+func (x composedOp) isOverwritten() bool {
+	switch x.Type {
+	case OperationAdd, OperationRemove, OperationReplace, OperationCopy:
+		return true
+	}
+	return false
+}
+
+// overwrites returns whether the operation replaces
+// the value located at its path, if any.
+func (x composedOp) overwrites() bool {
+	switch x.Type {
+	case OperationRemove, OperationReplace:
+		return true
+	case OperationAdd:
+		return !isIndex(x.path)
+	}
+	return false
+}
+
+// interferes returns whether the locations l1 and l2
+// depend on each other. The array indices that follow
+// an insertion or deletion are shifted, and therefore
+// depend on it.
+func (l1 location) interferes(l2 location) bool {
+	if !l1.write && !l2.write {
+		return false
+	}
+	if isPrefix(l1.ptr, l2.ptr) || isPrefix(l2.ptr, l1.ptr) {
+		return true
+	}
+	return l1.shift && l1.shifts(l2.ptr) || l2.shift && l2.shifts(l1.ptr)
+}
+
+// shifts returns whether the pointer p may refer to
+// an array element that follows the location l.
+func (l location) shifts(p engine.ParsedPointer) bool {
+	n := len(l.ptr.Tokens) - 1
+	if len(p.Tokens) <= n || !slices.Equal(l.ptr.Tokens[:n], p.Tokens[:n]) || !isIndexToken(p.Tokens[n]) {
+		return false
+	}
+	i, ok1 := engine.ParseIndex(l.ptr.Tokens[n])
+	j, ok2 := engine.ParseIndex(p.Tokens[n])
+
+	return !ok1 || !ok2 || j >= i
+}
+
+// isPrefix returns whether the pointer p is equal
+// to the pointer q, or is a prefix of it.
+func isPrefix(p, q engine.ParsedPointer) bool {
+	return len(p.Tokens) <= len(q.Tokens) && slices.Equal(p.Tokens, q.Tokens[:len(p.Tokens)])
+}
+
+// isIndex returns whether the last token of the
+// pointer may refer to an array element.
+func isIndex(p engine.ParsedPointer) bool {
+	return len(p.Tokens) != 0 && isIndexToken(p.Tokens[len(p.Tokens)-1])
+}
+
+// isAppend returns whether the last token of the
+// pointer refers to the end of an array.
+func isAppend(p engine.ParsedPointer) bool {
+	return len(p.Tokens) != 0 && p.Tokens[len(p.Tokens)-1] == "-"
+}
+
+func isIndexToken(tok string) bool {
+	if tok == "-" {
+		return true
+	}
+	_, ok := engine.ParseIndex(tok)
+	return ok
+}
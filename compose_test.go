@@ -0,0 +1,227 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+func TestCompose(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p1   string
+		p2   string
+		want string
+	}{
+		{
+			"add then remove",
+			`[{"op":"add","path":"/a","value":1}]`,
+			`[{"op":"remove","path":"/a"}]`,
+			`[]`,
+		},
+		{
+			"add then replace",
+			`[{"op":"add","path":"/a","value":1}]`,
+			`[{"op":"replace","path":"/a","value":2}]`,
+			`[{"op":"add","path":"/a","value":2}]`,
+		},
+		{
+			"replace then replace",
+			`[{"op":"replace","path":"/a","value":1}]`,
+			`[{"op":"replace","path":"/a","value":2}]`,
+			`[{"op":"replace","path":"/a","value":2}]`,
+		},
+		{
+			"replace then remove",
+			`[{"op":"replace","path":"/a","value":1}]`,
+			`[{"op":"remove","path":"/a"}]`,
+			`[{"op":"remove","path":"/a"}]`,
+		},
+		{
+			"remove then add",
+			`[{"op":"remove","path":"/a/1"}]`,
+			`[{"op":"add","path":"/a/1","value":2}]`,
+			`[{"op":"replace","path":"/a/1","value":2}]`,
+		},
+		{
+			"insertions",
+			`[{"op":"add","path":"/a/1","value":1}]`,
+			`[{"op":"add","path":"/a/1","value":2}]`,
+			`[{"op":"add","path":"/a/1","value":1},{"op":"add","path":"/a/1","value":2}]`,
+		},
+		{
+			"changes to an added value",
+			`[{"op":"add","path":"/a","value":{"b":[1]}}]`,
+			`[{"op":"add","path":"/a/b/-","value":2},{"op":"add","path":"/a/c","value":3},{"op":"test","path":"/a/c","value":3}]`,
+			`[{"op":"add","path":"/a","value":{"b":[1,2],"c":3}}]`,
+		},
+		{
+			"changes to a replaced value",
+			`[{"op":"replace","path":"/a","value":{"b":1}},{"op":"add","path":"/c","value":1}]`,
+			`[{"op":"remove","path":"/a/b"}]`,
+			`[{"op":"replace","path":"/a","value":{}},{"op":"add","path":"/c","value":1}]`,
+		},
+		{
+			"changes to a removed value",
+			`[{"op":"replace","path":"/a/b","value":1},{"op":"add","path":"/a/c","value":2},{"op":"add","path":"/d","value":3}]`,
+			`[{"op":"remove","path":"/a"}]`,
+			`[{"op":"add","path":"/d","value":3},{"op":"remove","path":"/a"}]`,
+		},
+		{
+			"invertible",
+			`[{"op":"test","path":"/a","value":1},{"op":"replace","path":"/a","value":2},{"op":"add","path":"/b","value":3}]`,
+			`[{"op":"test","path":"/a","value":2},{"op":"replace","path":"/a","value":4},{"op":"test","path":"/b","value":3},{"op":"remove","path":"/b"}]`,
+			`[{"op":"test","path":"/a","value":1},{"op":"replace","path":"/a","value":4}]`,
+		},
+		{
+			"test barrier",
+			`[{"op":"replace","path":"/a/b","value":1}]`,
+			`[{"op":"test","path":"/a","value":{"b":1}},{"op":"replace","path":"/a/b","value":2}]`,
+			`[{"op":"replace","path":"/a/b","value":1},{"op":"test","path":"/a","value":{"b":1}},{"op":"replace","path":"/a/b","value":2}]`,
+		},
+		{
+			"array shift",
+			`[{"op":"replace","path":"/a/1","value":1},{"op":"remove","path":"/a/0"}]`,
+			`[{"op":"replace","path":"/a/1","value":2}]`,
+			`[{"op":"replace","path":"/a/1","value":1},{"op":"remove","path":"/a/0"},{"op":"replace","path":"/a/1","value":2}]`,
+		},
+		{
+			"independent array elements",
+			`[{"op":"add","path":"/a/1","value":1},{"op":"replace","path":"/a/0","value":0},{"op":"replace","path":"/b/0","value":0}]`,
+			`[{"op":"remove","path":"/a/1"}]`,
+			`[{"op":"replace","path":"/a/0","value":0},{"op":"replace","path":"/b/0","value":0}]`,
+		},
+		{
+			"move barrier",
+			`[{"op":"add","path":"/a","value":1},{"op":"move","from":"/a","path":"/b"}]`,
+			`[{"op":"replace","path":"/b","value":2}]`,
+			`[{"op":"add","path":"/a","value":1},{"op":"move","from":"/a","path":"/b"},{"op":"replace","path":"/b","value":2}]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p1, p2, want Patch
+			for _, v := range []struct {
+				s string
+				p *Patch
+			}{{tc.p1, &p1}, {tc.p2, &p2}, {tc.want, &want}} {
+				if err := json.Unmarshal([]byte(v.s), v.p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := Compose(p1, p2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("got patch:\n%s\nwant:\n%s", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestCompose_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		p1    Patch
+		p2    Patch
+		err   error
+		index int
+		ptr   string
+	}{
+		{
+			"test failed",
+			Patch{{Type: OperationAdd, Path: "/a", Value: 1}},
+			Patch{{Type: OperationTest, Path: "/a", Value: 2}},
+			engine.ErrTestFailed, 1, "/a",
+		},
+		{
+			"missing child",
+			Patch{{Type: OperationReplace, Path: "/a", Value: map[string]any{}}},
+			Patch{{Type: OperationRemove, Path: "/a/b/c"}},
+			engine.ErrPathNotFound, 1, "/a/b",
+		},
+		{
+			"invalid pointer",
+			nil,
+			Patch{{Type: OperationRemove, Path: "a"}},
+			ErrInvalidOperation, 0, "a",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Compose(tc.p1, tc.p2)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			var (
+				perr *PatchError
+				aerr *engine.Error
+			)
+			if !errors.As(err, &perr) || !errors.As(err, &aerr) {
+				t.Fatalf("expected error of type %T", perr)
+			}
+			if perr.Index != tc.index || aerr.Pointer != tc.ptr {
+				t.Errorf("got index %d and pointer %q, want %d and %q", perr.Index, aerr.Pointer, tc.index, tc.ptr)
+			}
+		})
+	}
+}
+
+func TestCompose_compare(t *testing.T) {
+	docs := []string{
+		`{"a":1,"b":[1,2,3],"c":{"d":"e"}}`,
+		`{"a":2,"b":[1,3],"c":{"d":"f","g":[true]},"h":null}`,
+		`{"b":[0,1,3,4],"c":{"g":[true,false]},"h":{"i":1}}`,
+		`{"a":[],"b":[4],"c":{"d":"e"}}`,
+		`{"a":[],"b":[4],"c":{"d":"e"}}`,
+		`[1,{"a":2}]`,
+		`{"a":1}`,
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Invertible()},
+		{Factorize()},
+		{Rationalize(), LCS()},
+	} {
+		for i := 0; i+2 < len(docs); i++ {
+			a, b, c := docs[i], docs[i+1], docs[i+2]
+
+			p1, err := CompareJSON([]byte(a), []byte(b), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p2, err := CompareJSON([]byte(b), []byte(c), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Compose(p1, p2)
+			if err != nil {
+				t.Fatalf("%s -> %s -> %s: %s", a, b, c, err)
+			}
+			if len(p) > len(p1)+len(p2) {
+				t.Errorf("composed patch is larger than the input patches")
+			}
+			got, err := applyPatchJSON([]byte(a), p)
+			if err != nil {
+				t.Fatalf("%s -> %s -> %s: %s\npatch:\n%s", a, b, c, err, p.String())
+			}
+			if !jsonStringEqual(t, string(got), c) {
+				t.Errorf("%s -> %s -> %s: got %s\npatch:\n%s", a, b, c, got, p.String())
+			}
+		}
+	}
+}
+
+func jsonStringEqual(t *testing.T, s1, s2 string) bool {
+	t.Helper()
+
+	var v1, v2 any
+	if err := json.Unmarshal([]byte(s1), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(s2), &v2); err != nil {
+		t.Fatal(err)
+	}
+	return engine.Equal(v1, v2)
+}
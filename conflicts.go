@@ -0,0 +1,201 @@
+package jsondiff
+
+import (
+	"slices"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// Conflicts returns the operations of the patch and of the
+// other patch that touch the same location of a document, or
+// a location and one of its descendants, which means that the
+// patches, applied to the same document, cannot be applied in
+// any order with the same result. Two test operations never
+// conflict.
+//
+// The pointers of each operation are first rewritten to refer
+// to the document the patch applies to, which accounts for the
+// array indices shifted by the previous operations of the same
+// patch, and for the values they move. An insertion or deletion
+// of an array element also touches the elements that follow it,
+// and the ones of the other patch in the same array conflict with
+// it. Like the Transform function, a token of a pointer that is
+// an array index, or "-", is assumed to refer to an array element,
+// and the location appended by "-" is unknown. An operation whose
+// pointers are invalid touches the whole document.
+//
+// A Conflict is reported for each outermost conflicting location,
+// in the order of the pointers, along with the operations of both
+// patches that touch it or its descendants, in the order of each
+// patch. The values of a Conflict are not set, since the document
+// is unknown. The patches are not modified.
+func (p Patch) Conflicts(other Patch) []Conflict {
+	ours := patchLocations(p)
+	theirs := patchLocations(other)
+
+	type overlap struct {
+		ptr  engine.ParsedPointer
+		i, j int
+	}
+	var (
+		overlaps []overlap
+		ptrs     []engine.ParsedPointer
+	)
+	for i, ls1 := range ours {
+		for j, ls2 := range theirs {
+			for _, l1 := range ls1 {
+				for _, l2 := range ls2 {
+					if !l1.interferes(l2) {
+						continue
+					}
+					ptr := commonAncestor(l1.ptr, l2.ptr)
+					overlaps = append(overlaps, overlap{ptr: ptr, i: i, j: j})
+					ptrs = append(ptrs, ptr)
+				}
+			}
+		}
+	}
+	var res []Conflict
+	for _, ptr := range outermost(ptrs) {
+		var is, js []int
+		for _, o := range overlaps {
+			if isPrefix(ptr, o.ptr) {
+				is = append(is, o.i)
+				js = append(js, o.j)
+			}
+		}
+		c := Conflict{Pointer: ptr.Str}
+		for _, i := range sortedUnique(is) {
+			c.OurOps = append(c.OurOps, p[i])
+		}
+		for _, j := range sortedUnique(js) {
+			c.TheirOps = append(c.TheirOps, other[j])
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// patchLocations returns the locations read or written
+// by each operation of the patch, relative to the document
+// the patch applies to.
+func patchLocations(p Patch) [][]location {
+	var (
+		prev []composedOp
+		res  = make([][]location, len(p))
+	)
+	for i, op := range p {
+		x := composedOp{Operation: op}
+
+		var err1, err2 *engine.Error
+		x.path, err1 = engine.ParsePath(op.Path)
+		if op.hasFrom() {
+			x.from, err2 = engine.ParsePath(op.From)
+		}
+		if err1 != nil || err2 != nil {
+			res[i] = []location{{ptr: engine.ParsedPointer{}, write: true}}
+			continue
+		}
+		ls := x.locations()
+		for k := range ls {
+			for n := len(prev) - 1; n >= 0; n-- {
+				ls[k].ptr = prev[n].unapply(ls[k].ptr)
+			}
+		}
+		res[i] = ls
+		prev = append(prev, x)
+	}
+	return res
+}
+
+// unapply returns the location, in the document the operation
+// applies to, of the pointer p, which refers to the document
+// that results from the operation. The pointers to the values
+// added by the operation are rewritten to its path, and the
+// pointers to a moved value follow it back to its origin.
+func (x composedOp) unapply(p engine.ParsedPointer) engine.ParsedPointer {
+	switch x.Type {
+	case OperationAdd, OperationCopy:
+		return uninsert(x.path, p)
+	case OperationReplace:
+		if isPrefix(x.path, p) {
+			return x.path
+		}
+	case OperationRemove:
+		return unremove(x.path, p)
+	case OperationMove:
+		if isPrefix(x.path, p) {
+			return engine.JoinPointer(x.from.Tokens, p.Tokens[len(x.path.Tokens):])
+		}
+		return unremove(x.from, uninsert(x.path, p))
+	}
+	return p
+}
+
+// uninsert returns the pointer p that follows the addition
+// of a value at the location q, as it was before the value
+// was added.
+func uninsert(q, p engine.ParsedPointer) engine.ParsedPointer {
+	if isPrefix(q, p) {
+		return q
+	}
+	n, k, m, ok := siblingIndices(q, p)
+	if !ok || m < k {
+		return p
+	}
+	return withIndex(p, n, m-1)
+}
+
+// unremove returns the pointer p that follows the removal
+// of the value at the location q, as it was before the
+// value was removed.
+func unremove(q, p engine.ParsedPointer) engine.ParsedPointer {
+	n, k, m, ok := siblingIndices(q, p)
+	if !ok || m < k {
+		return p
+	}
+	return withIndex(p, n, m+1)
+}
+
+// siblingIndices returns the position n of the last token
+// of q, and the indices k and m of the array elements that
+// q and p refer to, if q is an array index, and p refers to
+// an element of the same array, or to one of its descendants.
+func siblingIndices(q, p engine.ParsedPointer) (n, k, m int, ok bool) {
+	n = len(q.Tokens) - 1
+	if n < 0 || len(p.Tokens) <= n || !slices.Equal(q.Tokens[:n], p.Tokens[:n]) {
+		return 0, 0, 0, false
+	}
+	k, ok1 := engine.ParseIndex(q.Tokens[n])
+	m, ok2 := engine.ParseIndex(p.Tokens[n])
+
+	return n, k, m, ok1 && ok2
+}
+
+// ancestor returns the pointer made of the
+// first n tokens of the pointer.
+func ancestor(p engine.ParsedPointer, n int) engine.ParsedPointer {
+	if n >= len(p.Tokens) {
+		return p
+	}
+	return engine.ParsedPointer{
+		Str:    p.Prefix(n),
+		Tokens: p.Tokens[:n],
+		Esc:    p.Esc[:n],
+	}
+}
+
+// commonAncestor returns the longest pointer
+// that is a prefix of both pointers p and q.
+func commonAncestor(p, q engine.ParsedPointer) engine.ParsedPointer {
+	n := 0
+	for n < len(p.Tokens) && n < len(q.Tokens) && p.Tokens[n] == q.Tokens[n] {
+		n++
+	}
+	return ancestor(p, n)
+}
+
+func sortedUnique(s []int) []int {
+	slices.Sort(s)
+	return slices.Compact(s)
+}
@@ -0,0 +1,116 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPatch_Conflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		p         string
+		other     string
+		conflicts []string // pointer, our ops, their ops
+	}{
+		{
+			"distinct members",
+			`[{"op":"replace","path":"/a","value":1},{"op":"remove","path":"/b/c"}]`,
+			`[{"op":"add","path":"/b/d","value":1},{"op":"remove","path":"/c"}]`,
+			nil,
+		},
+		{
+			"same member",
+			`[{"op":"replace","path":"/a","value":1}]`,
+			`[{"op":"add","path":"/b","value":1},{"op":"remove","path":"/a"}]`,
+			[]string{"/a", "0", "1"},
+		},
+		{
+			"ancestor",
+			`[{"op":"add","path":"/a/b/c","value":1},{"op":"replace","path":"/a/d","value":1}]`,
+			`[{"op":"replace","path":"/a","value":{}}]`,
+			[]string{"/a", "0,1", "0"},
+		},
+		{
+			"tests",
+			`[{"op":"test","path":"/a","value":1},{"op":"test","path":"/b","value":1}]`,
+			`[{"op":"test","path":"/a","value":1},{"op":"replace","path":"/b/c","value":2}]`,
+			[]string{"/b", "1", "1"},
+		},
+		{
+			"shifted array elements",
+			`[{"op":"add","path":"/a/1","value":1},{"op":"remove","path":"/b/3"}]`,
+			`[{"op":"replace","path":"/a/3","value":2},{"op":"replace","path":"/b/1","value":2}]`,
+			[]string{"/a", "0", "0"},
+		},
+		{
+			"distinct arrays",
+			`[{"op":"remove","path":"/a/0"},{"op":"replace","path":"/b/0","value":1}]`,
+			`[{"op":"add","path":"/b/1","value":2}]`,
+			nil,
+		},
+		{
+			"moved value",
+			`[{"op":"move","from":"/a","path":"/b"},{"op":"replace","path":"/b/c","value":1},{"op":"add","path":"/d","value":1}]`,
+			`[{"op":"replace","path":"/a/c","value":2},{"op":"remove","path":"/b"}]`,
+			[]string{"/a", "0,1", "0", "/b", "0", "1"},
+		},
+		{
+			"multiple conflicts",
+			`[{"op":"replace","path":"/b~1c","value":1},{"op":"remove","path":"/a/0"}]`,
+			`[{"op":"remove","path":"/b~1c"},{"op":"test","path":"/a/2/b","value":1}]`,
+			[]string{"/a", "1", "1", "/b~1c", "0", "0"},
+		},
+		{
+			"invalid pointer",
+			`[{"op":"replace","path":"a","value":1}]`,
+			`[{"op":"replace","path":"/b","value":1}]`,
+			[]string{"", "0", "0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p, other Patch
+			for _, v := range []struct {
+				s string
+				p *Patch
+			}{{tc.p, &p}, {tc.other, &other}} {
+				if err := json.Unmarshal([]byte(v.s), v.p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			conflicts := p.Conflicts(other)
+
+			if len(conflicts)*3 != len(tc.conflicts) {
+				t.Fatalf("got %d conflicts, want %d: %v", len(conflicts), len(tc.conflicts)/3, conflicts)
+			}
+			for i, c := range conflicts {
+				want := tc.conflicts[i*3 : i*3+3]
+				if c.Pointer != want[0] {
+					t.Errorf("got conflict pointer %q, want %q", c.Pointer, want[0])
+				}
+				for _, v := range []struct {
+					got   Patch
+					patch Patch
+					idx   string
+				}{{c.OurOps, p, want[1]}, {c.TheirOps, other, want[2]}} {
+					var ops Patch
+					for _, s := range strings.Split(v.idx, ",") {
+						n, err := strconv.Atoi(s)
+						if err != nil {
+							t.Fatal(err)
+						}
+						ops = append(ops, v.patch[n])
+					}
+					if v.got.String() != ops.String() {
+						t.Errorf("got conflicting operations:\n%s\nwant:\n%s", v.got.String(), ops.String())
+					}
+				}
+			}
+			// The conflicts are symmetric.
+			if n := len(other.Conflicts(p)); n != len(conflicts) {
+				t.Errorf("got %d conflicts in reverse order, want %d", n, len(conflicts))
+			}
+		})
+	}
+}
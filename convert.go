@@ -0,0 +1,408 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// ErrUnrepresentable is wrapped by the ConversionError returned
+// by Patch.ToMergePatch.
+var ErrUnrepresentable = errors.New("not representable as a merge patch")
+
+// A ConversionError is returned by Patch.ToMergePatch when an
+// operation cannot be represented by a JSON Merge Patch.
+type ConversionError struct {
+	// Op is the operation that cannot be represented,
+	// and Index its position in the patch.
+	Op    Operation
+	Index int
+
+	// Pointer is the location of the value that cannot
+	// be represented, and Reason describes the value.
+	Pointer string
+	Reason  string
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("jsondiff: operation #%d (%s %q): %s: %s at %q",
+		e.Index, e.Op.Type, e.Op.Path, ErrUnrepresentable, e.Reason, e.Pointer,
+	)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return ErrUnrepresentable
+}
+
+// ToMergePatch converts the patch into an equivalent JSON Merge
+// Patch (RFC 7386), which is returned in its JSON representation.
+//
+// A merge patch can only set or remove the members of objects:
+// the operations that change an array element, that set a value
+// to null, that move or copy a value, or that test a value, cannot
+// be represented, unless they are applied to a value set as a whole
+// by a previous operation of the patch. A value set to an object is
+// merged with the original value of the document, instead of being
+// replaced. Therefore, like the patches generated by the Compare
+// functions, an add operation whose path refers to an object member
+// is assumed to create that member, and the original value of a
+// replace or remove operation must be known, which is the case of
+// the operations generated by the Compare functions, if it is an
+// object. The document being unknown, a token of a pointer that is
+// an array index, or "-", is assumed to refer to an array element,
+// and the document is assumed to be an object, unless the patch
+// sets it as a whole.
+//
+// If an operation cannot be represented, an error of type
+// *ConversionError is returned. If it cannot be applied to the
+// value set by a previous operation, the error is of type
+// *PatchError.
+func (p Patch) ToMergePatch() ([]byte, error) {
+	c := mergeConverter{root: &mergeNode{}}
+
+	for i, op := range p {
+		if err := c.add(op, i); err != nil {
+			return nil, err
+		}
+	}
+	v, err := c.root.value()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// FromMergePatch converts the JSON Merge Patch (RFC 7386) mp into
+// a JSON Patch that is equivalent for the source document src: the
+// merge patch is applied to the JSON representation of src, and the
+// result is compared with it using the given options, like the
+// Compare function does. As such, with the StreamFunc option,
+// the operations are passed to its function, and the returned
+// patch is empty.
+func FromMergePatch(src any, mp []byte, opts ...Option) (Patch, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	if d.opts.marshal == nil {
+		d.opts.marshal = json.Marshal
+	}
+	if d.opts.unmarshal == nil {
+		d.opts.unmarshal = json.Unmarshal
+	}
+	si, _, err := marshalUnmarshal(src, d.opts)
+	if err != nil {
+		return nil, err
+	}
+	var patch any
+	if err := d.opts.unmarshal(mp, &patch); err != nil {
+		return nil, err
+	}
+	return compare(&d, si, applyMergePatch(engine.DeepCopy(si), patch))
+}
+
+// applyMergePatch applies the merge patch to the target
+// document, which may be modified, and returns the result.
+// https://datatracker.ietf.org/doc/html/rfc7386#section-2
+func applyMergePatch(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = make(map[string]any, len(pm))
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+			continue
+		}
+		tm[k] = applyMergePatch(tm[k], v)
+	}
+	return tm
+}
+
+// A mergeConverter converts the operations
+// of a patch into a JSON Merge Patch.
+type mergeConverter struct {
+	a    engine.Applier
+	root *mergeNode
+}
+
+// Kinds of original values.
+const (
+	origUnknown = iota
+	origAbsent
+	origKnown
+)
+
+// A mergeNode represents the changes of a location of the
+// document. Either the members of the original value are
+// changed individually, or the value is set as a whole.
+type mergeNode struct {
+	members map[string]*mergeNode
+
+	set     bool
+	removed bool
+	val     any    // value set
+	ptr     string // location of the value
+	op      Operation
+	idx     int // last operation that changed the value
+
+	// orig and kind describe the value of the location before
+	// it was set, and prev are the changes of its members made
+	// before, by which it differs from the original value of
+	// the document.
+	orig any
+	kind int
+	prev *mergeNode
+}
+
+// add merges the operation at position i of the
+// patch into the tree of changes.
+func (c *mergeConverter) add(op Operation, i int) error {
+	x := composedOp{Operation: op}
+
+	var err *engine.Error
+	if x.path, err = engine.ParsePath(op.Path); err != nil {
+		return patchError(err, i)
+	}
+	if op.hasFrom() {
+		if x.from, err = engine.ParsePath(op.From); err != nil {
+			return patchError(err, i)
+		}
+	}
+	var (
+		parent *mergeNode
+		node   = c.root
+	)
+	for j, tok := range x.path.Tokens {
+		if node.set {
+			return c.applyTo(node, x, i, j)
+		}
+		if isIndexToken(tok) {
+			return unrepresentable(x, i, ancestor(x.path, j+1), "array element")
+		}
+		if node.members == nil {
+			node.members = make(map[string]*mergeNode)
+		}
+		parent, node = node, node.members[tok]
+		if node == nil {
+			node = &mergeNode{}
+			parent.members[tok] = node
+		}
+	}
+	n := &mergeNode{
+		set:  true,
+		ptr:  op.Path,
+		op:   op,
+		idx:  i,
+		orig: op.OldValue,
+		kind: origKnown,
+	}
+	switch op.Type {
+	case OperationAdd, OperationReplace:
+		if n.val, err = c.a.Value(op.operation()); err != nil {
+			return patchError(err, i)
+		}
+	case OperationRemove:
+		if len(x.path.Tokens) == 0 {
+			return unrepresentable(x, i, x.path, "removal of the document")
+		}
+		n.removed = true
+	case OperationTest, OperationMove, OperationCopy:
+		if node.set {
+			return c.applyTo(node, x, i, len(x.path.Tokens))
+		}
+		if op.Type == OperationTest {
+			return unrepresentable(x, i, x.path, "test")
+		}
+		return unrepresentable(x, i, x.from, op.Type)
+	default:
+		err := &engine.Error{
+			Err:     fmt.Errorf("%w: unknown operation type %q", ErrInvalidOperation, op.Type),
+			Pointer: op.Path,
+		}
+		return patchError(err, i)
+	}
+	switch {
+	case node.set:
+		if node.removed && op.Type != OperationAdd {
+			err := &engine.Error{Err: engine.ErrPathNotFound, Pointer: op.Path}
+			return patchError(err, i)
+		}
+		// The value overwritten was set by a previous
+		// operation, and the original value is the one
+		// it replaced.
+		n.orig, n.kind, n.prev = node.orig, node.kind, node.prev
+	case len(node.members) != 0:
+		n.prev = node
+		if op.Type == OperationAdd || n.orig == nil {
+			n.kind = origUnknown
+		}
+	case op.Type == OperationAdd:
+		// Like the patches generated by the Compare
+		// functions, the member is assumed to be created.
+		n.kind = origAbsent
+	case n.orig == nil:
+		n.kind = origUnknown
+	}
+	if parent == nil {
+		c.root = n
+	} else {
+		parent.members[x.path.Tokens[len(x.path.Tokens)-1]] = n
+	}
+	return nil
+}
+
+// applyTo applies the operation x at position i of the patch
+// to the value set by the node located at the first n tokens
+// of its path.
+func (c *mergeConverter) applyTo(node *mergeNode, x composedOp, i, n int) error {
+	prefix := ancestor(x.path, n)
+	if node.removed {
+		err := &engine.Error{Err: engine.ErrPathNotFound, Pointer: prefix.Str}
+		return patchError(err, i)
+	}
+	if x.hasFrom() && !isPrefix(prefix, x.from) {
+		return unrepresentable(x, i, x.from, x.Type)
+	}
+	rel := x.Operation
+	rel.Path = engine.JoinPointer(x.path.Tokens[n:]).Str
+	if x.hasFrom() {
+		rel.From = engine.JoinPointer(x.from.Tokens[n:]).Str
+	}
+	if err := c.a.ApplyOp(&node.val, rel.operation()); err != nil {
+		err.Pointer = prefix.Str + err.Pointer
+		return patchError(err, i)
+	}
+	if x.Type != OperationTest {
+		node.op, node.idx = x.Operation, i
+	}
+	return nil
+}
+
+// value returns the merge patch of the changes of the node.
+func (n *mergeNode) value() (any, error) {
+	if !n.set {
+		m := make(map[string]any, len(n.members))
+		for k, c := range n.members {
+			v, err := c.value()
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+	if n.removed {
+		return nil, nil
+	}
+	if msg := nullValue(n.val); msg != "" {
+		return nil, n.error(msg)
+	}
+	v, ok := n.merge(n.val)
+	if !ok {
+		return nil, n.error("object replacing a value that is unknown")
+	}
+	return v, nil
+}
+
+// merge returns the merge patch that sets the original value
+// described by the node to v, and whether it is known enough.
+func (n *mergeNode) merge(v any) (any, bool) {
+	vm, ok := v.(map[string]any)
+	if !ok || n.kind == origAbsent {
+		return v, true
+	}
+	if n.kind == origUnknown {
+		return nil, false
+	}
+	om, ok := n.orig.(map[string]any)
+	if !ok {
+		return v, true
+	}
+	var prev map[string]*mergeNode
+	if n.prev != nil {
+		prev = n.prev.members
+	}
+	res := make(map[string]any)
+
+	// The members that may exist in the original
+	// value, but not in v, are removed.
+	for k := range om {
+		if _, ok := vm[k]; !ok {
+			res[k] = nil
+		}
+	}
+	for k := range prev {
+		if _, ok := vm[k]; !ok {
+			res[k] = nil
+		}
+	}
+	for k, x := range vm {
+		child, ok := prev[k]
+		ov, found := om[k]
+
+		switch {
+		case ok && child.set:
+			// The member was set before, and child
+			// describes its original value.
+		case ok:
+			child = &mergeNode{orig: ov, kind: origKnown, prev: child}
+		case !found:
+			res[k] = x
+			continue
+		case engine.Equal(ov, x):
+			continue
+		default:
+			child = &mergeNode{orig: ov, kind: origKnown}
+		}
+		cv, ok := child.merge(x)
+		if !ok {
+			return nil, false
+		}
+		res[k] = cv
+	}
+	return res, true
+}
+
+func (n *mergeNode) error(msg string) *ConversionError {
+	return &ConversionError{
+		Op:      n.op,
+		Index:   n.idx,
+		Pointer: n.ptr,
+		Reason:  msg,
+	}
+}
+
+// nullValue returns a description of the null value
+// in v, which a merge patch interprets as the removal
+// of a member, if any.
+func nullValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null value"
+	case map[string]any:
+		for _, mv := range val {
+			if nullValue(mv) != "" {
+				return "null member value"
+			}
+		}
+	}
+	return ""
+}
+
+// unrepresentable returns the error of the operation x at
+// position i of a patch, which sets the value at p that
+// cannot be represented.
+func unrepresentable(x composedOp, i int, p engine.ParsedPointer, what string) *ConversionError {
+	return &ConversionError{
+		Op:      x.Operation,
+		Index:   i,
+		Pointer: p.Str,
+		Reason:  what,
+	}
+}
@@ -0,0 +1,257 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+func TestPatch_ToMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch string
+		want  string
+		err   error
+		index int
+		ptr   string
+	}{
+		{
+			"members",
+			`[
+				{"op":"add","path":"/a","value":{"b":1}},
+				{"op":"replace","path":"/c/d","value":[1,null]},
+				{"op":"remove","path":"/c/e~1f"}
+			]`,
+			`{"a":{"b":1},"c":{"d":[1,null],"e/f":null}}`,
+			nil, 0, "",
+		},
+		{
+			"changes of a set value",
+			`[
+				{"op":"add","path":"/a","value":{"b":[1,2],"c":1}},
+				{"op":"remove","path":"/a/b/0"},
+				{"op":"move","from":"/a/c","path":"/a/d"},
+				{"op":"test","path":"/a/d","value":1}
+			]`,
+			`{"a":{"b":[2],"d":1}}`,
+			nil, 0, "",
+		},
+		{
+			"removed then added",
+			`[
+				{"op":"remove","path":"/a"},
+				{"op":"add","path":"/a","value":2}
+			]`,
+			`{"a":2}`,
+			nil, 0, "",
+		},
+		{
+			"document",
+			`[{"op":"replace","path":"","value":[1,2]}]`,
+			`[1,2]`,
+			nil, 0, "",
+		},
+		{
+			"empty patch",
+			`[]`,
+			`{}`,
+			nil, 0, "",
+		},
+		{
+			"array element",
+			`[{"op":"add","path":"/b","value":1},{"op":"replace","path":"/a/0/b","value":1}]`,
+			``,
+			ErrUnrepresentable, 1, "/a/0",
+		},
+		{
+			"null value",
+			`[{"op":"add","path":"/a","value":null}]`,
+			``,
+			ErrUnrepresentable, 0, "/a",
+		},
+		{
+			"null member value",
+			`[{"op":"add","path":"/a","value":{}},{"op":"add","path":"/a/b","value":null}]`,
+			``,
+			ErrUnrepresentable, 1, "/a",
+		},
+		{
+			"replaced object",
+			`[{"op":"replace","path":"/a","value":{"b":1}}]`,
+			``,
+			ErrUnrepresentable, 0, "/a",
+		},
+		{
+			"move",
+			`[{"op":"move","from":"/a","path":"/b"}]`,
+			``,
+			ErrUnrepresentable, 0, "/a",
+		},
+		{
+			"test",
+			`[{"op":"test","path":"/a","value":1}]`,
+			``,
+			ErrUnrepresentable, 0, "/a",
+		},
+		{
+			"missing value",
+			`[{"op":"add","path":"/a","value":{}},{"op":"remove","path":"/a/b"}]`,
+			``,
+			engine.ErrPathNotFound, 1, "/a/b",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p Patch
+			if err := json.Unmarshal([]byte(tc.patch), &p); err != nil {
+				t.Fatal(err)
+			}
+			b, err := p.ToMergePatch()
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("got error %v, want %v", err, tc.err)
+				}
+				var (
+					index int
+					ptr   string
+				)
+				if tc.err == ErrUnrepresentable {
+					var cerr *ConversionError
+					if !errors.As(err, &cerr) {
+						t.Fatalf("got error %v, want *ConversionError", err)
+					}
+					if cerr.Op.Type != p[cerr.Index].Type || cerr.Op.Path != p[cerr.Index].Path {
+						t.Errorf("got operation %v, want %v", cerr.Op, p[cerr.Index])
+					}
+					index, ptr = cerr.Index, cerr.Pointer
+				} else {
+					var (
+						perr *PatchError
+						aerr *engine.Error
+					)
+					if !errors.As(err, &perr) || !errors.As(err, &aerr) {
+						t.Fatalf("got error %v, want *PatchError", err)
+					}
+					index, ptr = perr.Index, aerr.Pointer
+				}
+				if index != tc.index {
+					t.Errorf("got index %d, want %d", index, tc.index)
+				}
+				if ptr != tc.ptr {
+					t.Errorf("got pointer %q, want %q", ptr, tc.ptr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonStringEqual(t, string(b), tc.want) {
+				t.Errorf("got %s, want %s", b, tc.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchConversion(t *testing.T) {
+	type testcase struct {
+		Name string `json:"name"`
+		B    any    `json:"before"`
+		A    any    `json:"after"`
+		P    any    `json:"patch"`
+	}
+	for _, testFile := range []string{
+		"testdata/tests/mergepatch/rfc.json",
+		"testdata/tests/mergepatch/array.json",
+		"testdata/tests/mergepatch/object.json",
+	} {
+		name := strings.TrimSuffix(filepath.Base(testFile), filepath.Ext(testFile))
+
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cases []testcase
+			if err := json.Unmarshal(b, &cases); err != nil {
+				t.Fatal(err)
+			}
+			for _, tc := range cases {
+				t.Run(tc.Name, func(t *testing.T) {
+					mp, err := json.Marshal(tc.P)
+					if err != nil {
+						t.Fatal(err)
+					}
+					patch, err := FromMergePatch(tc.B, mp)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := applyPatch(tc.B, patch)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !engine.Equal(got, tc.A) {
+						t.Errorf("got %v, want %v", got, tc.A)
+					}
+					// Convert the patch back, if possible.
+					mp, err = patch.ToMergePatch()
+					if errors.Is(err, ErrUnrepresentable) {
+						return
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					var v any
+					if err := json.Unmarshal(mp, &v); err != nil {
+						t.Fatal(err)
+					}
+					if _, ok := tc.B.(map[string]any); !ok {
+						// The merge patch replaces a document
+						// that is not an object.
+						return
+					}
+					if got := applyMergePatch(engine.DeepCopy(tc.B), v); !engine.Equal(got, tc.A) {
+						t.Errorf("got %v from merge patch %s, want %v", got, mp, tc.A)
+					}
+				})
+			}
+		})
+	}
+	if _, err := FromMergePatch(nil, []byte(`{`)); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestFromMergePatch_stream(t *testing.T) {
+	src := map[string]any{"a": 1, "b": map[string]any{"c": "d"}}
+	mp := []byte(`{"a":null,"b":{"e":true}}`)
+
+	want, err := FromMergePatch(src, mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Patch
+	patch, err := FromMergePatch(src, mp, StreamFunc(func(op Operation) error {
+		got = append(got, op)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("got %d operations in the returned patch, want none", len(patch))
+	}
+	if len(want) == 0 || got.String() != want.String() {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got.String(), want.String())
+	}
+	errStop := errors.New("stop")
+	_, err = FromMergePatch(src, mp, StreamFunc(func(Operation) error {
+		return errStop
+	}))
+	if !errors.Is(err, errStop) {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+}
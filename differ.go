@@ -1,20 +1,27 @@
 package jsondiff
 
 import (
+	"context"
 	"slices"
 	"strings"
 	"unsafe"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 // A Differ generates JSON Patch (RFC 6902).
 // The zero value is an empty generator ready to use.
 type Differ struct {
+	ctx              context.Context
+	err              error
 	hashmap          map[uint64]jsonNode
+	removed          map[uint64][]int
+	moved            []movedOp
 	opts             options
 	patch            Patch
 	snapshotPatchLen int
 	targetBytes      []byte
-	ptr              pointer
+	ptr              engine.Pointer
 	hasher           hasher
 	isCompact        bool
 	compactInPlace   bool
@@ -23,18 +30,59 @@ type Differ struct {
 type (
 	marshalFunc   func(any) ([]byte, error)
 	unmarshalFunc func([]byte, any) error
+	ignoreFunc    func(path string, src, tgt any) bool
+	equalFunc     func(a, b any) bool
+	streamFunc    func(op Operation) error
 )
 
 type options struct {
+	floatTols   []floatTolerance
+	equalFuncs  map[string]equalFunc
+	equalGlobs  []equalGlob
 	ignores     map[string]struct{}
+	ignoreGlobs [][]string
+	ignoreFunc  ignoreFunc
+	stream      streamFunc
+	arrayKeys   map[string]string
+	keyGlobs    []keyGlob
 	marshal     marshalFunc
 	unmarshal   unmarshalFunc
 	hasIgnore   bool
+	hasKeys     bool
+	customEqual bool
 	factorize   bool
 	rationalize bool
 	invertible  bool
+	oldValues   bool
 	equivalent  bool
 	lcs         bool
+	maxDepth    int
+	maxOps      int
+}
+
+// keyGlob associates a pointer pattern with the name
+// of the identity field of the arrays it matches.
+type keyGlob struct {
+	pattern []string
+	key     string
+}
+
+// equalGlob associates a pointer pattern with the
+// equality function of the values it matches.
+type equalGlob struct {
+	pattern []string
+	fn      equalFunc
+}
+
+// movedOp is a remove operation of the patch, at position
+// idx, that the factorization replaced by a move. The moves
+// are recorded with the Rationalize and MaxOperations options,
+// to restore the removals when the operations of a container
+// are discarded.
+type movedOp struct {
+	op  Operation
+	idx int
+	key uint64
 }
 
 type jsonNode struct {
@@ -46,12 +94,17 @@ type jsonNode struct {
 // underlying storage for use by future comparisons.
 func (d *Differ) Reset() {
 	d.patch = d.patch[:0]
-	d.ptr.reset()
+	d.moved = d.moved[:0]
+	d.ptr.Reset()
+	d.err = nil
 
 	// Optimized map clear.
 	for k := range d.hashmap {
 		delete(d.hashmap, k)
 	}
+	for k := range d.removed {
+		delete(d.removed, k)
+	}
 }
 
 // WithOpts applies the given options to the Differ
@@ -66,16 +119,25 @@ func (d *Differ) WithOpts(opts ...Option) *Differ {
 // Patch returns the list of JSON patch operations
 // generated by the Differ instance. The patch is
 // valid for usage until the next comparison or reset.
+// It is empty if the operations were passed to the
+// function of the StreamFunc option.
 func (d *Differ) Patch() Patch {
 	return d.patch
 }
 
+// Err returns the error that interrupted the last
+// comparison, if any, such as the one returned by
+// the function of the StreamFunc option.
+func (d *Differ) Err() error {
+	return d.err
+}
+
 // Compare computes the differences between src and tgt
 // as a series of JSON Patch operations.
 func (d *Differ) Compare(src, tgt interface{}) {
 	if d.opts.factorize {
 		d.prepare(d.ptr, src, tgt)
-		d.ptr.reset()
+		d.ptr.Reset()
 	}
 	if d.opts.rationalize {
 		if !d.isCompact {
@@ -87,51 +149,112 @@ func (d *Differ) Compare(src, tgt interface{}) {
 		}
 	}
 	d.diff(d.ptr, src, tgt, b2s(d.targetBytes))
+	d.flush(true)
+
+	if d.opts.oldValues {
+		for i := range d.patch {
+			d.patch[i].withOld = true
+		}
+	}
 }
 
-func (d *Differ) isIgnored(ptr pointer) bool {
+// flush passes the operations of the patch to the function
+// of the StreamFunc option, and empties the patch. Unless the
+// comparison is over, the operations are kept if they may
+// still be rewritten by the factorization, rationalization
+// or the maximum number of operations.
+func (d *Differ) flush(done bool) {
+	if d.opts.stream == nil || len(d.patch) == 0 {
+		return
+	}
+	if !done && (d.opts.factorize || d.opts.rationalize || d.opts.maxOps > 0) {
+		return
+	}
+	if d.err == nil {
+		for _, op := range d.patch {
+			op.withOld = d.opts.oldValues
+			if err := d.opts.stream(op); err != nil {
+				d.err = err
+				break
+			}
+		}
+	}
+	d.patch = d.patch[:0]
+}
+
+func (d *Differ) isIgnored(ptr engine.Pointer, src, tgt interface{}) bool {
 	// Fast path, inlined map check.
 	if !d.opts.hasIgnore {
 		return false
 	}
 	// Slow path.
 	// Outlined so that the fast path can be inlined.
-	return d.findIgnored(ptr)
+	return d.findIgnored(ptr, src, tgt)
 }
 
-func (d *Differ) findIgnored(ptr pointer) bool {
-	_, found := d.opts.ignores[ptr.string()]
-	return found
+func (d *Differ) findIgnored(ptr engine.Pointer, src, tgt interface{}) bool {
+	s := ptr.String()
+	if _, found := d.opts.ignores[s]; found {
+		return true
+	}
+	for _, g := range d.opts.ignoreGlobs {
+		if engine.MatchGlob(g, s) {
+			return true
+		}
+	}
+	if d.opts.ignoreFunc != nil {
+		return d.opts.ignoreFunc(ptr.Copy(), src, tgt)
+	}
+	return false
 }
 
-func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
-	if d.isIgnored(ptr) {
+func (d *Differ) diff(ptr engine.Pointer, src, tgt interface{}, doc string) {
+	if d.stopped() {
+		return
+	}
+	if d.isIgnored(ptr, src, tgt) {
 		return
 	}
 	if !areComparable(src, tgt) {
-		if ptr.isRoot() {
+		// Values of different types may still be equal
+		// according to a custom equality function.
+		if fn := d.findEqualFunc(ptr); fn != nil && fn(src, tgt) {
+			return
+		}
+		if ptr.IsRoot() {
 			// If incomparable values are located at the root
 			// of the document, use an add operation to replace
 			// the entire content of the document.
 			// https://tools.ietf.org/html/rfc6902#section-4.1
-			d.patch = d.patch.append(OperationAdd, emptyPointer, ptr.copy(), src, tgt, 0)
+			d.patch = d.patch.append(OperationAdd, emptyPointer, ptr.Copy(), src, tgt, 0)
 		} else {
 			// Values are incomparable, generate a replacement.
-			d.replace(ptr.copy(), src, tgt, doc)
+			d.replace(ptr.Copy(), src, tgt, doc)
 		}
 		return
 	}
-	if deepEqual(src, tgt) {
+	if d.equal(ptr, src, tgt) {
+		return
+	}
+	if d.opts.maxDepth > 0 && ptr.Depth() >= d.opts.maxDepth && isContainer(src) {
+		// Do not compare the values nested deeper than
+		// the maximum depth, replace the container.
+		d.replace(ptr.Copy(), src, tgt, doc)
 		return
 	}
 	// Save the current size of the patch to detect later
 	// on if we have new operations to rationalize.
-	size := len(d.patch)
+	size, moved := len(d.patch), len(d.moved)
 
 	// Values are comparable, but are not
 	// equivalent.
 	switch val := src.(type) {
 	case []interface{}:
+		if d.opts.hasKeys {
+			if key, ok := d.findArrayKey(ptr); ok && d.compareArraysKeyed(ptr, val, tgt.([]interface{}), key, doc) {
+				break
+			}
+		}
 		if d.opts.lcs {
 			d.compareArraysLCS(ptr, val, tgt.([]interface{}), doc)
 		} else {
@@ -143,42 +266,124 @@ func (d *Differ) diff(ptr pointer, src, tgt interface{}, doc string) {
 		// Generate a replace operation for
 		// scalar types.
 		if !deepEqual(src, tgt) {
-			d.replace(ptr.copy(), src, tgt, doc)
+			d.replace(ptr.Copy(), src, tgt, doc)
 			return
 		}
 	}
 	// Rationalize new operations, if any.
 	if d.opts.rationalize && len(d.patch) > size {
-		d.rationalize(ptr, src, tgt, size, doc)
+		d.rationalize(ptr, src, tgt, size, moved, doc)
+	}
+	// If the patch exceeds the maximum number of operations,
+	// replace the operations of the container by a single
+	// replacement. Since the check is repeated by all the
+	// parent containers, the patch fits in the budget, unless
+	// it is lower than the operations of the root replacement.
+	if d.opts.maxOps > 0 && len(d.patch) > d.opts.maxOps && len(d.patch) > size+1 {
+		d.rollback(size, moved)
+		if ptr.IsRoot() {
+			d.patch = d.patch.append(OperationAdd, emptyPointer, ptr.Copy(), src, tgt, 0)
+		} else {
+			d.replace(ptr.Copy(), src, tgt, doc)
+		}
+	}
+}
+
+// start returns the position of the first operation added
+// to the patch since it had size operations, and since there
+// were moved recorded moves, whose removals may precede it.
+func (d *Differ) start(size, moved int) int {
+	for _, m := range d.moved[moved:] {
+		if m.idx < size {
+			size--
+		}
+	}
+	return size
+}
+
+// rollback discards the operations added to the patch since
+// it had size operations, and the moves recorded since there
+// were moved of them. The remove operations that preceded the
+// discarded operations, and were replaced by those moves, are
+// restored.
+func (d *Differ) rollback(size, moved int) {
+	var restored []movedOp
+	for _, m := range d.moved[moved:] {
+		if m.idx < size {
+			restored = append(restored, m)
+			size--
+		}
+	}
+	d.patch = d.patch[:size]
+	d.dropRemoved(size)
+
+	for i := len(restored) - 1; i >= 0; i-- {
+		m := restored[i]
+		d.patch = slices.Insert(d.patch, m.idx, m.op)
+		d.shiftRemoved(m.idx, 1)
+		d.indexRemoved(m.key, m.idx)
+	}
+	d.moved = d.moved[:moved]
+}
+
+// stopped returns whether the comparison must be
+// interrupted because the context of the Differ is done.
+func (d *Differ) stopped() bool {
+	if d.err != nil {
+		return true
+	}
+	if d.ctx == nil {
+		return false
+	}
+	select {
+	case <-d.ctx.Done():
+		d.err = d.ctx.Err()
+		return true
+	default:
+		return false
 	}
 }
 
-func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
+func (d *Differ) prepare(ptr engine.Pointer, src, tgt interface{}) {
 	// When both values are deeply equals, save
 	// the location indexed by the value hash.
 	if !areComparable(src, tgt) {
 		return
-	} else if deepEqual(src, tgt) {
-		k := d.hasher.digest(tgt, false)
+	} else if d.equal(ptr, src, tgt) {
+		// The source value is saved, since it is the
+		// one found at this location when the patch
+		// is applied, even if it differs from the target
+		// according to the comparison options.
+		k := d.hasher.digest(src, false)
 		if d.hashmap == nil {
 			d.hashmap = make(map[uint64]jsonNode)
 		}
 		d.hashmap[k] = jsonNode{
-			ptr: ptr.copy(),
-			val: tgt,
+			ptr: ptr.Copy(),
+			val: src,
 		}
 		return
 	}
+	if d.stopped() || (d.opts.maxDepth > 0 && ptr.Depth() >= d.opts.maxDepth) {
+		return
+	}
 	// At this point, the source and target values
 	// are non-nil and have comparable types.
 	switch vsrc := src.(type) {
 	case []interface{}:
+		if d.opts.hasKeys {
+			// The elements of keyed arrays are not
+			// paired by position, skip them.
+			if _, ok := d.findArrayKey(ptr); ok {
+				return
+			}
+		}
 		oarr := vsrc
 		narr := tgt.([]interface{})
 
 		for i := 0; i < min(len(oarr), len(narr)); i++ {
-			p := ptr.clone()
-			p.appendIndex(i)
+			p := ptr.Clone()
+			p.AppendIndex(i)
 			d.prepare(p, oarr[i], narr[i])
 		}
 	case map[string]interface{}:
@@ -187,8 +392,8 @@ func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
 
 		for k, v1 := range oobj {
 			if v2, ok := nobj[k]; ok {
-				p := ptr.clone()
-				p.appendKey(k)
+				p := ptr.Clone()
+				p.AppendKey(k)
 				d.prepare(p, v1, v2)
 			}
 		}
@@ -197,27 +402,27 @@ func (d *Differ) prepare(ptr pointer, src, tgt interface{}) {
 	}
 }
 
-func (d *Differ) rationalize(ptr pointer, src, tgt interface{}, lastOpIdx int, doc string) {
+func (d *Differ) rationalize(ptr engine.Pointer, src, tgt interface{}, lastOpIdx, moved int, doc string) {
 	// replaceOp represents a single operation that
 	// replace the source document with the target.
 	replaceOp := Operation{
 		Type:     OperationReplace,
-		Path:     ptr.string(), // shallow copy
+		Path:     ptr.String(), // shallow copy
 		OldValue: src,
 		Value:    tgt,
 		valueLen: len(doc),
 	}
-	curOps := d.patch[lastOpIdx:]
+	curOps := d.patch[d.start(lastOpIdx, moved):]
 	curLen := curOps.jsonLength()
 
 	// If one operation is cheaper than many small
 	// operations that represents the changes between
 	// the two objects, replace the last operations.
 	if curLen > replaceOp.jsonLength() {
-		d.patch = d.patch[:lastOpIdx]
+		d.rollback(lastOpIdx, moved)
 
 		// Allocate a new string for the operation's path.
-		replaceOp.Path = ptr.copy()
+		replaceOp.Path = ptr.Copy()
 
 		if d.opts.invertible {
 			d.patch = d.patch.append(OperationTest, emptyPointer, replaceOp.Path, nil, src, len(doc))
@@ -228,7 +433,7 @@ func (d *Differ) rationalize(ptr pointer, src, tgt interface{}, lastOpIdx int, d
 
 // compareObjects generates the patch operations that
 // represents the differences between two JSON objects.
-func (d *Differ) compareObjects(ptr pointer, src, tgt map[string]interface{}, doc string) {
+func (d *Differ) compareObjects(ptr engine.Pointer, src, tgt map[string]interface{}, doc string) {
 	cmpSet := make(map[string]uint8, max(len(src), len(tgt)))
 
 	for k := range src {
@@ -243,38 +448,38 @@ func (d *Differ) compareObjects(ptr pointer, src, tgt map[string]interface{}, do
 	}
 	sortStrings(keys)
 
-	ptr.snapshot()
+	ptr.Snapshot()
 	for _, k := range keys {
 		v := cmpSet[k]
 		inOld := v&(1<<0) != 0
 		inNew := v&(1<<1) != 0
 
-		ptr.appendKey(k)
+		ptr.AppendKey(k)
 
 		switch {
 		case inOld && inNew:
 			if d.opts.rationalize {
-				d.diff(ptr, src[k], tgt[k], findKey(doc, ptr.base.key))
+				d.diff(ptr, src[k], tgt[k], findKey(doc, ptr.Key()))
 			} else {
 				d.diff(ptr, src[k], tgt[k], doc)
 			}
 		case inOld:
-			if !d.isIgnored(ptr) {
-				d.remove(ptr.copy(), src[k])
+			if !d.isIgnored(ptr, src[k], nil) {
+				d.remove(ptr.Copy(), src[k], true)
 			}
 		case inNew:
-			if !d.isIgnored(ptr) {
-				d.add(ptr.copy(), tgt[k], doc, false)
+			if !d.isIgnored(ptr, nil, tgt[k]) {
+				d.add(ptr.Copy(), tgt[k], doc, false)
 			}
 		}
-		ptr.rewind()
+		ptr.Rewind()
 	}
 }
 
 // compareArrays generates the patch operations that
 // represents the differences between two JSON arrays.
-func (d *Differ) compareArrays(ptr pointer, src, tgt []interface{}, doc string) {
-	ptr.snapshot()
+func (d *Differ) compareArrays(ptr engine.Pointer, src, tgt []interface{}, doc string) {
+	ptr.Snapshot()
 	sl, tl := len(src), len(tgt)
 	ml := min(sl, tl)
 
@@ -283,59 +488,62 @@ func (d *Differ) compareArrays(ptr pointer, src, tgt []interface{}, doc string)
 	// from the destination and the removal index
 	// is always equal to the original array length.
 	if tl < sl {
-		np := ptr.clone()
-		np.appendIndex(ml) // "removal" path
-		p := np.copy()
+		np := ptr.Clone()
+		np.AppendIndex(ml) // "removal" path
+		p := np.Copy()
 		for i := ml; i < sl; i++ {
-			ptr.appendIndex(i)
+			ptr.AppendIndex(i)
 
-			if !d.isIgnored(ptr) {
-				d.remove(p, src[i])
+			if !d.isIgnored(ptr, src[i], nil) {
+				d.remove(p, src[i], true)
 			}
-			ptr.rewind()
+			ptr.Rewind()
 		}
 		goto comparisons // skip equivalence test since arrays are different
 	}
-	if d.opts.equivalent && d.unorderedDeepEqualSlice(src, tgt) {
+	if d.opts.equivalent && d.unorderedDeepEqualSlice(ptr, src, tgt) {
 		return
 	}
 comparisons:
 	// Compare the elements at each index present in
 	// both the source and destination arrays.
 	for i := 0; i < ml; i++ {
-		ptr.appendIndex(i)
+		ptr.AppendIndex(i)
 		if d.opts.rationalize {
-			d.diff(ptr, src[i], tgt[i], findIndex(doc, ptr.base.idx))
+			d.diff(ptr, src[i], tgt[i], findIndex(doc, ptr.Index()))
 		} else {
 			d.diff(ptr, src[i], tgt[i], doc)
 		}
-		ptr.rewind()
+		ptr.Rewind()
 	}
 	// When the target array contains more elements
 	// than the source, entries are appended to the
 	// destination.
 	if tl > sl {
-		np := ptr.clone()
-		np.appendKey("-") // "append" path
-		p := np.copy()
+		np := ptr.Clone()
+		np.AppendKey("-") // "append" path
+		p := np.Copy()
 		for i := ml; i < tl; i++ {
-			ptr.appendIndex(i)
-			if !d.isIgnored(ptr) {
+			ptr.AppendIndex(i)
+			if !d.isIgnored(ptr, nil, tgt[i]) {
 				d.add(p, tgt[i], doc, false)
 			}
-			ptr.rewind()
+			ptr.Rewind()
 		}
 	}
 }
 
-func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc string) {
+func (d *Differ) compareArraysLCS(ptr engine.Pointer, src, tgt []interface{}, doc string) {
 	if len(src) == len(tgt) {
-		if d.opts.equivalent && d.unorderedDeepEqualSlice(src, tgt) {
+		if d.opts.equivalent && d.unorderedDeepEqualSlice(ptr, src, tgt) {
 			return
 		}
 	}
-	ptr.snapshot()
-	pairs := lcs(src, tgt)
+	ptr.Snapshot()
+	pairs := d.lcs(ptr, src, tgt)
+	if d.err != nil {
+		return
+	}
 	d.snapshotPatchLen = len(d.patch)
 
 	var ai, bi int // src && tgt arrows
@@ -361,34 +569,34 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 				// Both arrows points to an item before the
 				// current match index, which indicate an
 				// equal amount of different items.
-				ptr.appendIndex(adjust(ai))
+				ptr.AppendIndex(adjust(ai))
 				if d.opts.rationalize {
-					d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.base.idx))
+					d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.Index()))
 				} else {
 					d.diff(ptr, src[ai], tgt[bi], doc)
 				}
-				ptr.rewind()
+				ptr.Rewind()
 				ai++
 				bi++
 			case ai < ma:
 				// The left arrow representing the source slice
 				// is lower than the current match index, which
 				// indicate that a preceding item has been removed.
-				ptr.appendIndex(adjust(ai))
+				ptr.AppendIndex(adjust(ai))
 
-				if !d.isIgnored(ptr) {
-					d.remove(ptr.copy(), src[ai])
+				if !d.isIgnored(ptr, src[ai], nil) {
+					d.remove(ptr.Copy(), src[ai], true)
 				}
-				ptr.rewind()
+				ptr.Rewind()
 				ai++
 				removes++
 			default: // bi < mb
 				// Opposite case of the previous condition.
-				ptr.appendIndex(bi)
-				if !d.isIgnored(ptr) {
-					d.add(ptr.copy(), tgt[bi], doc, true)
+				ptr.AppendIndex(bi)
+				if !d.isIgnored(ptr, nil, tgt[bi]) {
+					d.add(ptr.Copy(), tgt[bi], doc, true)
 				}
-				ptr.rewind()
+				ptr.Rewind()
 				bi++
 				adds++
 			}
@@ -406,40 +614,251 @@ func (d *Differ) compareArraysLCS(ptr pointer, src, tgt []interface{}, doc strin
 	for ai < len(src) || bi < len(tgt) {
 		switch {
 		case ai < len(src) && bi < len(tgt):
-			ptr.appendIndex(adjust(ai))
+			ptr.AppendIndex(adjust(ai))
 			if d.opts.rationalize {
-				d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.base.idx))
+				d.diff(ptr, src[ai], tgt[bi], findIndex(doc, ptr.Index()))
 			} else {
 				d.diff(ptr, src[ai], tgt[bi], doc)
 			}
-			ptr.rewind()
+			ptr.Rewind()
 			ai++
 			bi++
 		case ai < len(src):
-			ptr.appendIndex(adjust(ai))
+			ptr.AppendIndex(adjust(ai))
 
-			if !d.isIgnored(ptr) {
-				d.remove(ptr.copy(), src[ai])
+			if !d.isIgnored(ptr, src[ai], nil) {
+				d.remove(ptr.Copy(), src[ai], true)
 			}
-			ptr.rewind()
+			ptr.Rewind()
 			ai++
 			removes++
 		default: // bi < len(tgt)
-			ptr.appendIndex(bi)
-			if !d.isIgnored(ptr) {
-				d.add(ptr.copy(), tgt[bi], doc, true)
+			ptr.AppendIndex(bi)
+			if !d.isIgnored(ptr, nil, tgt[bi]) {
+				d.add(ptr.Copy(), tgt[bi], doc, true)
 			}
-			ptr.rewind()
+			ptr.Rewind()
 			bi++
 			adds++
 		}
 	}
 }
 
-func (d *Differ) unorderedDeepEqualSlice(src, tgt []interface{}) bool {
+// findArrayKey returns the name of the identity field
+// used to pair the elements of the array located at ptr.
+func (d *Differ) findArrayKey(ptr engine.Pointer) (string, bool) {
+	s := ptr.String()
+	if k, ok := d.opts.arrayKeys[s]; ok {
+		return k, true
+	}
+	for _, g := range d.opts.keyGlobs {
+		if engine.MatchGlob(g.pattern, s) {
+			return g.key, true
+		}
+	}
+	return "", false
+}
+
+// keyIndex indexes the elements of an array
+// by the digest of their identity field value.
+type keyIndex struct {
+	ids     []interface{}
+	digests map[uint64]int
+}
+
+// indexArrayKeys returns the index of the elements of
+// the array by identity. It returns false if one of the
+// elements is not an object with the identity field,
+// or if two elements share the same identity.
+func (d *Differ) indexArrayKeys(arr []interface{}, key string) (keyIndex, bool) {
+	idx := keyIndex{
+		ids:     make([]interface{}, len(arr)),
+		digests: make(map[uint64]int, len(arr)),
+	}
+	for i, v := range arr {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return idx, false
+		}
+		id, ok := obj[key]
+		if !ok {
+			return idx, false
+		}
+		k := d.hasher.digest(id, false)
+		if _, found := idx.digests[k]; found {
+			return idx, false
+		}
+		idx.ids[i] = id
+		idx.digests[k] = i
+	}
+	return idx, true
+}
+
+// compareArraysKeyed generates the patch operations that
+// represents the differences between two JSON arrays of
+// objects, whose elements are paired by the value of
+// their identity field. It returns false without generating
+// any operation if the elements cannot be paired.
+func (d *Differ) compareArraysKeyed(ptr engine.Pointer, src, tgt []interface{}, key, doc string) bool {
+	sidx, ok := d.indexArrayKeys(src, key)
+	if !ok {
+		return false
+	}
+	tidx, ok := d.indexArrayKeys(tgt, key)
+	if !ok {
+		return false
+	}
+	if len(src) == len(tgt) && d.opts.equivalent && d.unorderedDeepEqualSlice(ptr, src, tgt) {
+		return true
+	}
+	// Pair the elements of both arrays, smatch[i] is
+	// the index in the target array of the source
+	// element i, or -1 if it was removed, and tmatch
+	// is the opposite.
+	smatch := make([]int, len(src))
+	tmatch := make([]int, len(tgt))
+	for j := range tmatch {
+		tmatch[j] = -1
+	}
+	for i, id := range sidx.ids {
+		smatch[i] = -1
+		j, ok := tidx.digests[d.hasher.digest(id, false)]
+		if ok && deepEqual(id, tidx.ids[j]) {
+			smatch[i] = j
+			tmatch[j] = i
+		}
+	}
+	ptr.Snapshot()
+
+	// Remove the unpaired elements of the source array
+	// in descending order, so that the index of the
+	// preceding elements is preserved.
+	for i := len(src) - 1; i >= 0; i-- {
+		if smatch[i] != -1 {
+			continue
+		}
+		ptr.AppendIndex(i)
+		if !d.isIgnored(ptr, src[i], nil) {
+			// The moves that reorder the paired elements
+			// depend on the removal, which must not be
+			// replaced by a later move.
+			d.remove(ptr.Copy(), src[i], false)
+		}
+		ptr.Rewind()
+	}
+	// The cur slice holds the source indices of the
+	// paired elements in their current order, and the
+	// order slice holds the same indices, sorted in
+	// the order of the target array.
+	cur := make([]int, 0, len(src))
+	for i, j := range smatch {
+		if j != -1 {
+			cur = append(cur, i)
+		}
+	}
+	order := make([]int, 0, len(cur))
+	ranks := make([]int, 0, len(cur))
+	for _, i := range tmatch {
+		if i != -1 {
+			order = append(order, i)
+			ranks = append(ranks, slices.Index(cur, i))
+		}
+	}
+	// The elements that are part of the longest increasing
+	// subsequence of ranks keep their relative order. Others
+	// are moved right after the element that precedes them
+	// in the target array.
+	stable := make([]bool, len(src))
+	for _, k := range lis(ranks) {
+		stable[order[k]] = true
+	}
+	for k, i := range order {
+		if stable[i] {
+			continue
+		}
+		from := slices.Index(cur, i)
+		cur = slices.Delete(cur, from, from+1)
+		to := 0
+		if k > 0 {
+			to = slices.Index(cur, order[k-1]) + 1
+		}
+		cur = slices.Insert(cur, to, i)
+
+		if from != to {
+			ptr.AppendIndex(from)
+			fp := ptr.Copy()
+			ptr.Rewind()
+			ptr.AppendIndex(to)
+			d.patch = d.patch.append(OperationMove, fp, ptr.Copy(), src[i], src[i], 0)
+			d.flush(false)
+			ptr.Rewind()
+		}
+	}
+	// Insert the unpaired elements of the target array
+	// in ascending order, which moves the paired elements
+	// to their final index.
+	for j, i := range tmatch {
+		if i != -1 {
+			continue
+		}
+		ptr.AppendIndex(j)
+		if !d.isIgnored(ptr, nil, tgt[j]) {
+			if d.opts.rationalize {
+				d.add(ptr.Copy(), tgt[j], findIndex(doc, j), false)
+			} else {
+				d.add(ptr.Copy(), tgt[j], doc, false)
+			}
+		}
+		ptr.Rewind()
+	}
+	// Compare the paired elements.
+	for j, i := range tmatch {
+		if i == -1 {
+			continue
+		}
+		ptr.AppendIndex(j)
+		if d.opts.rationalize {
+			d.diff(ptr, src[i], tgt[j], findIndex(doc, j))
+		} else {
+			d.diff(ptr, src[i], tgt[j], doc)
+		}
+		ptr.Rewind()
+	}
+	return true
+}
+
+// lcs computes the longest common subsequence of the
+// arrays located at ptr, according to the comparison
+// options of the Differ. The computation is interrupted
+// if the context of the Differ is done.
+func (d *Differ) lcs(ptr engine.Pointer, src, tgt []interface{}) [][2]int {
+	var m myers
+	if d.opts.customEqual {
+		eq := func(i, j int) bool {
+			p := ptr.Clone()
+			p.AppendIndex(i)
+			return d.equalValue(p, src[i], tgt[j], false)
+		}
+		m = myers{equal: eq, match: eq}
+	} else {
+		m = newMyers(src, tgt, &d.hasher)
+	}
+	if d.ctx != nil {
+		m.stop = d.stopped
+	}
+	return m.lcs(len(src), len(tgt))
+}
+
+func (d *Differ) unorderedDeepEqualSlice(ptr engine.Pointer, src, tgt []interface{}) bool {
 	if len(src) != len(tgt) {
 		return false
 	}
+	if d.opts.customEqual {
+		// Values that are equal according to the
+		// comparison options may have different
+		// digests, compare them one by one instead.
+		return d.unorderedEqual(ptr, src, tgt)
+	}
 	diff := make(map[uint64]struct{}, len(src))
 	count := 0
 
@@ -467,15 +886,19 @@ func (d *Differ) replace(path string, src, tgt interface{}, doc string) {
 		d.patch = d.patch.append(OperationTest, emptyPointer, path, nil, src, vl)
 	}
 	d.patch = d.patch.append(OperationReplace, emptyPointer, path, src, tgt, vl)
+	d.flush(false)
 }
 
 func (d *Differ) add(path string, v interface{}, doc string, lcs bool) {
 	if !d.opts.factorize {
 		d.patch = d.patch.append(OperationAdd, emptyPointer, path, nil, v, 0)
+		d.flush(false)
 		return
 	}
-	idx := d.findRemoved(v)
+	k := d.hasher.digest(v, false)
+	idx := d.findRemoved(path, k, v)
 	if idx != -1 {
+		rk := d.removedKey(k)
 		op := d.patch[idx]
 
 		// https://tools.ietf.org/html/rfc6902#section-4.4f
@@ -483,16 +906,23 @@ func (d *Differ) add(path string, v interface{}, doc string, lcs bool) {
 		// of the "path" location; i.e., a location cannot
 		// be moved into one of its children.
 		if !strings.HasPrefix(path, op.Path) {
+			d.unindexRemoved(rk, idx)
 			d.patch = d.patch.remove(idx)
+			d.shiftRemoved(idx, -1)
+
+			if d.opts.rationalize || d.opts.maxOps > 0 {
+				d.moved = append(d.moved, movedOp{op: op, idx: idx, key: rk})
+			}
 			if !lcs {
 				d.patch = d.patch.append(OperationMove, op.Path, path, v, v, 0)
 			} else {
+				d.shiftRemoved(min(d.snapshotPatchLen, len(d.patch)), 1)
 				d.patch = d.patch.insert(d.snapshotPatchLen, OperationMove, op.Path, path, v, v, 0)
 			}
 		}
 		return
 	}
-	uptr := d.findUnchanged(v)
+	uptr := d.findUnchanged(path, k, v)
 
 	if len(uptr) != 0 && !d.opts.invertible {
 		d.patch = d.patch.append(OperationCopy, uptr, path, nil, v, 0)
@@ -501,28 +931,131 @@ func (d *Differ) add(path string, v interface{}, doc string, lcs bool) {
 	}
 }
 
-func (d *Differ) remove(path string, v interface{}) {
+// remove appends the remove operation of the value v located
+// at path. Unless it is not movable, the factorization may
+// replace it later by the move of the value to another path.
+func (d *Differ) remove(path string, v interface{}, movable bool) {
 	if d.opts.invertible {
 		d.patch = d.patch.append(OperationTest, emptyPointer, path, nil, v, 0)
 	}
 	d.patch = d.patch.append(OperationRemove, emptyPointer, path, v, nil, 0)
+	d.flush(false)
+
+	if movable && d.opts.factorize {
+		// Index the removed values by digest, to
+		// speed up the lookups of findRemoved.
+		var k uint64
+		if !d.opts.customEqual {
+			k = d.hasher.digest(v, false)
+		}
+		d.indexRemoved(k, len(d.patch)-1)
+	}
+}
+
+// removedKey returns the key of the index of the removed
+// values for a value whose digest is k. Values that are
+// equal according to the comparison options may have
+// different digests, in which case they share a key.
+func (d *Differ) removedKey(k uint64) uint64 {
+	if d.opts.customEqual {
+		return 0
+	}
+	return k
+}
+
+// indexRemoved records the position i in the patch of the
+// remove operation of a value whose digest is k.
+func (d *Differ) indexRemoved(k uint64, i int) {
+	if d.removed == nil {
+		d.removed = make(map[uint64][]int)
+	}
+	ps := d.removed[k]
+	j, _ := slices.BinarySearch(ps, i)
+	d.removed[k] = slices.Insert(ps, j, i)
+}
+
+// unindexRemoved forgets the position i of the remove
+// operation of a value whose digest is k.
+func (d *Differ) unindexRemoved(k uint64, i int) {
+	ps := d.removed[k]
+	if j, ok := slices.BinarySearch(ps, i); ok {
+		d.removed[k] = slices.Delete(ps, j, j+1)
+	}
+}
+
+// shiftRemoved adds n to the positions of the remove
+// operations located at or after the position i, when
+// operations are inserted or removed before them.
+func (d *Differ) shiftRemoved(i, n int) {
+	for _, ps := range d.removed {
+		for j := len(ps) - 1; j >= 0 && ps[j] >= i; j-- {
+			ps[j] += n
+		}
+	}
+}
+
+// dropRemoved forgets the remove operations located at
+// or after the position i, which are discarded.
+func (d *Differ) dropRemoved(i int) {
+	for k, ps := range d.removed {
+		n := len(ps)
+		for n > 0 && ps[n-1] >= i {
+			n--
+		}
+		d.removed[k] = ps[:n]
+	}
 }
 
-func (d *Differ) findUnchanged(v interface{}) string {
-	if d.hashmap != nil {
-		k := d.hasher.digest(v, false)
-		node, ok := d.hashmap[k]
-		if ok {
-			return node.ptr
+// findUnchanged returns the location of a value that is
+// unchanged between the source and target documents, and
+// equal to the value v, whose digest is k, added at path.
+func (d *Differ) findUnchanged(path string, k uint64, v interface{}) string {
+	if d.hashmap == nil {
+		return emptyPointer
+	}
+	if d.opts.customEqual {
+		// Values that are equal according to the
+		// comparison options may have different
+		// digests, compare them one by one, and
+		// pick the first location in lexicographical
+		// order for the result to be deterministic.
+		ptr := engine.NewPointer(path)
+		uptr := emptyPointer
+		for _, node := range d.hashmap {
+			if (uptr == emptyPointer || node.ptr < uptr) && d.equalValue(ptr, node.val, v, false) {
+				uptr = node.ptr
+			}
 		}
+		return uptr
+	}
+	// Distinct values may have the same digest,
+	// confirm the match.
+	node, ok := d.hashmap[k]
+	if ok && deepEqual(node.val, v) {
+		return node.ptr
 	}
 	return emptyPointer
 }
 
-func (d *Differ) findRemoved(v interface{}) int {
-	for i := 0; i < len(d.patch); i++ {
-		op := d.patch[i]
-		if op.Type == OperationRemove && deepEqual(op.OldValue, v) {
+// findRemoved returns the index in the patch of the
+// remove operation of the value v, whose digest is k,
+// added at path.
+func (d *Differ) findRemoved(path string, k uint64, v interface{}) int {
+	if d.opts.customEqual {
+		ptr := engine.NewPointer(path)
+		for _, i := range d.removed[d.removedKey(k)] {
+			if d.equalValue(ptr, d.patch[i].OldValue, v, false) {
+				return i
+			}
+		}
+		return -1
+	}
+	// Only the remove operations of the values with the
+	// same digest are compared, in the order of the patch.
+	// Distinct values may have the same digest, confirm
+	// the match.
+	for _, i := range d.removed[k] {
+		if deepEqual(d.patch[i].OldValue, v) {
 			return i
 		}
 	}
@@ -7,11 +7,46 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
+// applyPatch applies the patch to a copy of the document
+// with the engine of the jsonpatch package, which cannot be
+// imported by the tests of this package.
+func applyPatch(doc any, patch Patch) (any, error) {
+	ops := make([]engine.Operation, len(patch))
+	for i, op := range patch {
+		ops[i] = op.operation()
+	}
+	v, f, err := engine.NewApplier(engine.Options{}).Apply(doc, ops)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		return nil, patchError(&engine.Error{Err: f.Err, Pointer: f.Pointer}, f.Index)
+	}
+	return v, nil
+}
+
+// applyPatchJSON is like applyPatch, for the
+// JSON representation of the document.
+func applyPatchJSON(src []byte, patch Patch) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	v, err := applyPatch(doc, patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
 var testNameReplacer = strings.NewReplacer(",", "", "(", "", ")", "")
 
 type testcase struct {
@@ -35,10 +70,7 @@ func TestRootCases(t *testing.T)   { runCasesFromFile(t, "testdata/tests/jsonpat
 
 func TestDiffer_Reset(t *testing.T) {
 	d := &Differ{
-		ptr: pointer{
-			buf: make([]byte, 15),
-			sep: 15,
-		},
+		ptr: engine.NewPointer("/a/b/c"),
 		hashmap: map[uint64]jsonNode{
 			1: {},
 		},
@@ -52,11 +84,8 @@ func TestDiffer_Reset(t *testing.T) {
 	if l := len(d.hashmap); l != 0 {
 		t.Errorf("expected cleared hashmap, got length %d", l)
 	}
-	if d.ptr.sep != 0 {
-		t.Errorf("expected reset ptr")
-	}
-	if l := len(d.ptr.buf); l != 0 {
-		t.Errorf("expected empty ptr buf, got length %d", l)
+	if !d.ptr.IsRoot() {
+		t.Errorf("expected reset ptr, got %q", d.ptr.String())
 	}
 }
 
@@ -75,6 +104,15 @@ func TestOptions(t *testing.T) {
 		{"testdata/tests/jsonpatch/options/lcs.json", makeOpts(LCS(), Factorize())},
 		{"testdata/tests/jsonpatch/options/all.json", makeOpts(Factorize(), Rationalize(), Invertible(), Equivalent())},
 		{"testdata/tests/jsonpatch/options/lcs+equivalence.json", makeOpts(LCS(), Equivalent())},
+		{"testdata/tests/jsonpatch/options/old-values.json", makeOpts(OldValues())},
+		{"testdata/tests/jsonpatch/options/keys.json", makeOpts(ArrayKeys(map[string]string{
+			"/items":            "name",
+			"/groups/*/members": "id",
+		}))},
+		{"testdata/tests/jsonpatch/options/keys+factorization.json", makeOpts(Factorize(), ArrayKeys(map[string]string{
+			"/items":            "name",
+			"/groups/*/members": "id",
+		}))},
 	} {
 		name := strings.TrimSuffix(filepath.Base(tc.testFile), filepath.Ext(tc.testFile))
 		t.Run(name, func(t *testing.T) {
@@ -161,11 +199,14 @@ func runTestCase(t *testing.T, tc testcase, pc patchGetter, opts ...Option) {
 				t.Errorf("op #%d mismatch: value: unequal", i)
 			}
 		}
+		if want.withOld && !reflect.DeepEqual(op.OldValue, want.OldValue) {
+			t.Errorf("op #%d mismatch: old value: unequal", i)
+		}
 	}
 	// Unsupported cases:
 	//  * the Ignores() option is enabled
 	//  * explicitly disabled for individual test case
-	if d.opts.ignores != nil || tc.SkipApplyTest {
+	if d.opts.hasIgnore || tc.SkipApplyTest {
 		return
 	}
 	mustMarshal := func(v any) []byte {
@@ -179,19 +220,68 @@ func runTestCase(t *testing.T, tc testcase, pc patchGetter, opts ...Option) {
 	// Validate that the patch is fundamentally correct by
 	// applying it to the source document, and compare the
 	// result with the expected document.
-	b, err := patch.apply(mustMarshal(tc.Before), false)
+	doc, err := applyPatch(tc.Before, patch)
 	if err != nil {
 		t.Errorf("failed to apply patch: %s", err)
+		return
 	}
-	// Re-marshal the patched document to ensure it follows
+	// Marshal the patched document to ensure it follows
 	// the Golang JSON convention of ordering map keys, and
 	// can be compared to the target document.
-	before, after := unmarshalMarshal(t, b), mustMarshal(tc.After)
+	got, want := mustMarshal(doc), mustMarshal(tc.After)
 
-	if !bytes.Equal(before, after) {
+	if !bytes.Equal(got, want) {
 		t.Errorf("patch does not produce the expected changes")
-		t.Logf("got: %s", string(before))
-		t.Logf("want: %s", string(after))
+		t.Logf("got: %s", string(got))
+		t.Logf("want: %s", string(want))
+	}
+}
+
+func TestDiffer_ignoreFunc(t *testing.T) {
+	src := []byte(`{"a":1,"x-a":2,"b":[1,2],"c":{"x-c":1},"x-d":4}`)
+	tgt := []byte(`{"a":2,"x-a":3,"b":[1,3,4],"c":{},"x-e":5}`)
+
+	var calls []string
+	patch, err := CompareJSON(src, tgt, LCS(), IgnoreFunc(func(path string, _, _ any) bool {
+		calls = append(calls, path)
+		return strings.Contains(path, "/x-") || path == "/b/2"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Patch{
+		{Type: OperationReplace, Path: "/a", Value: float64(2)},
+		{Type: OperationReplace, Path: "/b/1", Value: float64(3)},
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch.String())
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path || !deepEqual(op.Value, want[i].Value) {
+			t.Errorf("op #%d mismatch: got %s, want %s", i, op, want[i])
+		}
+	}
+	for _, p := range []string{"", "/a", "/b", "/b/2", "/c/x-c", "/x-d", "/x-e"} {
+		if !slices.Contains(calls, p) {
+			t.Errorf("expected ignore func to be called for path %q", p)
+		}
+	}
+}
+
+func TestDiffer_ignoreFunc_values(t *testing.T) {
+	src := map[string]interface{}{"ts": float64(100), "v": float64(1)}
+	tgt := map[string]interface{}{"ts": float64(130), "v": float64(2)}
+
+	d := Differ{}
+	d.WithOpts(IgnoreFunc(func(path string, src, tgt any) bool {
+		s, ok1 := src.(float64)
+		t, ok2 := tgt.(float64)
+		return path == "/ts" && ok1 && ok2 && t-s < 60
+	}))
+	d.Compare(src, tgt)
+
+	if p := d.Patch(); len(p) != 1 || p[0].Path != "/v" {
+		t.Errorf("expected a single operation at path /v, got:\n%s", p.String())
 	}
 }
 
@@ -229,7 +319,7 @@ func TestDiffer_unorderedDeepEqualSlice(t *testing.T) {
 		},
 	} {
 		d := Differ{}
-		eq := d.unorderedDeepEqualSlice(tc.src, tc.tgt)
+		eq := d.unorderedDeepEqualSlice(engine.Pointer{}, tc.src, tc.tgt)
 		if eq != tc.equal {
 			t.Errorf("equality mismatch, got %t, want %t", eq, tc.equal)
 		}
@@ -428,17 +518,3 @@ func Benchmark_sortStrings(b *testing.B) {
 		})
 	}
 }
-
-func unmarshalMarshal(t *testing.T, b []byte) []byte {
-	t.Helper()
-
-	var i interface{}
-	if err := json.Unmarshal(b, &i); err != nil {
-		t.Error(err)
-	}
-	b2, err := json.Marshal(i)
-	if err != nil {
-		t.Error(err)
-	}
-	return b2
-}
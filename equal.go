@@ -2,57 +2,20 @@ package jsondiff
 
 import (
 	"encoding/json"
-	"strconv"
+	"math"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 type invalidJSONTypeError struct {
 	t any
 }
 
-// jsonValueType represents the type of JSON value.
-// It follows the types of values stored by json.Unmarshal
-// in interface values.
-type jsonValueType uint
-
-const (
-	jsonInvalid jsonValueType = iota
-	jsonNull
-	jsonString
-	jsonBoolean
-	jsonNumberFloat
-	jsonNumberString
-	jsonArray
-	jsonObject
-)
-
-// jsonTypeSwitch returns the JSON type of the value
-// held by the interface using a type switch statement.
-func jsonTypeSwitch(i interface{}) jsonValueType {
-	switch i.(type) {
-	case nil:
-		return jsonNull
-	case string:
-		return jsonString
-	case bool:
-		return jsonBoolean
-	case float64:
-		return jsonNumberFloat
-	case json.Number:
-		return jsonNumberString
-	case []interface{}:
-		return jsonArray
-	case map[string]interface{}:
-		return jsonObject
-	default:
-		return jsonInvalid
-	}
-}
-
 // areComparable returns whether the interface values
 // i1 and i2 can be compared. The values are comparable
 // only if they are both non-nil and share the same kind.
 func areComparable(i1, i2 interface{}) bool {
-	return jsonTypeSwitch(i1) == jsonTypeSwitch(i2)
+	return engine.TypeOf(i1) == engine.TypeOf(i2)
 }
 
 func deepEqual(src, tgt interface{}) bool {
@@ -64,29 +27,29 @@ func deepEqual(src, tgt interface{}) bool {
 }
 
 func deepEqualValue(src, tgt interface{}) bool {
-	st := jsonTypeSwitch(src)
-	if st == jsonInvalid {
+	st := engine.TypeOf(src)
+	if st == engine.Invalid {
 		panic(invalidJSONTypeError{t: src})
 	}
-	tt := jsonTypeSwitch(tgt)
-	if tt == jsonInvalid {
+	tt := engine.TypeOf(tgt)
+	if tt == engine.Invalid {
 		panic(invalidJSONTypeError{t: tgt})
 	}
 	if st != tt {
 		return false
 	}
 	switch st {
-	case jsonNull:
+	case engine.Null:
 		return true
-	case jsonString:
+	case engine.String:
 		return src.(string) == tgt.(string)
-	case jsonBoolean:
+	case engine.Boolean:
 		return src.(bool) == tgt.(bool)
-	case jsonNumberFloat:
+	case engine.NumberFloat:
 		return src.(float64) == tgt.(float64)
-	case jsonNumberString:
-		return src.(json.Number) == tgt.(json.Number)
-	case jsonArray:
+	case engine.NumberString:
+		return engine.EqualNumber(src.(json.Number), tgt.(json.Number))
+	case engine.Array:
 		oarr := src.([]interface{})
 		narr := tgt.([]interface{})
 
@@ -99,7 +62,7 @@ func deepEqualValue(src, tgt interface{}) bool {
 			}
 		}
 		return true
-	case jsonObject:
+	case engine.Object:
 		oobj := src.(map[string]interface{})
 		nobj := tgt.(map[string]interface{})
 
@@ -122,21 +85,177 @@ func deepEqualValue(src, tgt interface{}) bool {
 	}
 }
 
-var jsonTypeNames = []string{
-	jsonInvalid:      "Invalid",
-	jsonBoolean:      "Boolean",
-	jsonNumberFloat:  "Number",
-	jsonNumberString: "json.Number",
-	jsonString:       "String",
-	jsonNull:         "Null",
-	jsonObject:       "Object",
-	jsonArray:        "Array",
+// floatTolerance represents the tolerance used to
+// compare two floating-point numbers, scoped to the
+// values located at specific pointers, if any.
+type floatTolerance struct {
+	abs   float64
+	rel   float64
+	ptrs  map[string]struct{}
+	globs [][]string
+}
+
+func (t floatTolerance) matches(ptr engine.Pointer) bool {
+	if t.ptrs == nil && t.globs == nil {
+		return true
+	}
+	s := ptr.String()
+	if _, ok := t.ptrs[s]; ok {
+		return true
+	}
+	for _, g := range t.globs {
+		if engine.MatchGlob(g, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// findEqualFunc returns the custom equality function
+// of the values located at ptr, if any.
+func (d *Differ) findEqualFunc(ptr engine.Pointer) equalFunc {
+	if d.opts.equalFuncs == nil && d.opts.equalGlobs == nil {
+		return nil
+	}
+	s := ptr.String()
+	if fn, ok := d.opts.equalFuncs[s]; ok {
+		return fn
+	}
+	for _, g := range d.opts.equalGlobs {
+		if engine.MatchGlob(g.pattern, s) {
+			return g.fn
+		}
+	}
+	return nil
+}
+
+// equal returns whether the src and tgt values located
+// at ptr are equal, according to the comparison options
+// of the Differ.
+func (d *Differ) equal(ptr engine.Pointer, src, tgt interface{}) bool {
+	// Fast path, no comparison options.
+	if !d.opts.customEqual {
+		return deepEqual(src, tgt)
+	}
+	return d.equalValue(ptr, src, tgt, false)
+}
+
+// equalValue is similar to deepEqualValue, but it honors
+// the comparison options of the Differ. If unordered is
+// true, the order of the elements of arrays is ignored.
+func (d *Differ) equalValue(ptr engine.Pointer, src, tgt interface{}, unordered bool) bool {
+	if fn := d.findEqualFunc(ptr); fn != nil {
+		return fn(src, tgt)
+	}
+	st := engine.TypeOf(src)
+	if st == engine.Invalid {
+		panic(invalidJSONTypeError{t: src})
+	}
+	tt := engine.TypeOf(tgt)
+	if tt == engine.Invalid {
+		panic(invalidJSONTypeError{t: tgt})
+	}
+	if st != tt {
+		return false
+	}
+	switch st {
+	case engine.NumberFloat:
+		return d.equalFloat(ptr, src.(float64), tgt.(float64))
+	case engine.NumberString:
+		n1, n2 := src.(json.Number), tgt.(json.Number)
+		if engine.EqualNumber(n1, n2) {
+			return true
+		}
+		if len(d.opts.floatTols) != 0 {
+			// The tolerance is applied to the
+			// nearest floating-point values.
+			f1, err1 := n1.Float64()
+			f2, err2 := n2.Float64()
+			if err1 == nil && err2 == nil {
+				return d.equalFloat(ptr, f1, f2)
+			}
+		}
+		return false
+	case engine.Array:
+		oarr := src.([]interface{})
+		narr := tgt.([]interface{})
+
+		if len(oarr) != len(narr) {
+			return false
+		}
+		if unordered {
+			return d.unorderedEqual(ptr, oarr, narr)
+		}
+		for i := 0; i < len(oarr); i++ {
+			p := ptr.Clone()
+			p.AppendIndex(i)
+			if !d.equalValue(p, oarr[i], narr[i], false) {
+				return false
+			}
+		}
+		return true
+	case engine.Object:
+		oobj := src.(map[string]interface{})
+		nobj := tgt.(map[string]interface{})
+
+		if len(oobj) != len(nobj) {
+			return false
+		}
+		for k, v1 := range oobj {
+			v2, ok := nobj[k]
+			if !ok {
+				// Key not found in target.
+				return false
+			}
+			p := ptr.Clone()
+			p.AppendKey(k)
+			if !d.equalValue(p, v1, v2, unordered) {
+				return false
+			}
+		}
+		return true
+	default:
+		return deepEqualValue(src, tgt)
+	}
+}
+
+// unorderedEqual returns whether the arrays of equal
+// length contain the same elements, regardless of their
+// order. Each element of the source array is paired with
+// the first unpaired element of the target array it is
+// equal to, which takes quadratic time.
+func (d *Differ) unorderedEqual(ptr engine.Pointer, src, tgt []interface{}) bool {
+	paired := make([]bool, len(tgt))
+	for i, v := range src {
+		p := ptr.Clone()
+		p.AppendIndex(i)
+
+		found := false
+		for j := range tgt {
+			if !paired[j] && d.equalValue(p, v, tgt[j], true) {
+				paired[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
-// String implements fmt.Stringer for jsonValueType.
-func (t jsonValueType) String() string {
-	if uint(t) < uint(len(jsonTypeNames)) {
-		return jsonTypeNames[t]
+// equalFloat returns whether the two numbers located at ptr
+// are equal within the first float tolerance that applies.
+func (d *Differ) equalFloat(ptr engine.Pointer, f1, f2 float64) bool {
+	if f1 == f2 {
+		return true
+	}
+	for _, t := range d.opts.floatTols {
+		if t.matches(ptr) {
+			diff := math.Abs(f1 - f2)
+			return diff <= t.abs || diff <= t.rel*max(math.Abs(f1), math.Abs(f2))
+		}
 	}
-	return "type" + strconv.Itoa(int(t))
+	return false
 }
@@ -2,95 +2,13 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
-)
-
-func reflectKind(i interface{}) reflect.Kind {
-	return reflect.TypeOf(i).Kind()
-}
-
-func BenchmarkGetType(b *testing.B) {
-	if testing.Short() {
-		b.Skip("skipping benchmark in short mode")
-	}
-	m := map[string]interface{}{}
-
-	b.Run("reflect", func(b *testing.B) {
-		for b.Loop() {
-			_ = reflectKind(m)
-		}
-	})
-	b.Run("typeSwitch", func(b *testing.B) {
-		for b.Loop() {
-			_ = jsonTypeSwitch(m)
-		}
-	})
-}
 
-func Test_jsonTypeSwitch(t *testing.T) {
-	for _, tc := range []struct {
-		val   any
-		valid bool
-		kind  jsonValueType
-	}{
-		{
-			"foo",
-			true,
-			jsonString,
-		},
-		{
-			false,
-			true,
-			jsonBoolean,
-		},
-		{
-			float32(3.14),
-			false,
-			jsonInvalid,
-		},
-		{
-			nil,
-			true,
-			jsonNull,
-		},
-		{
-			&struct{}{},
-			false,
-			jsonInvalid,
-		},
-		{
-			3.14,
-			true,
-			jsonNumberFloat,
-		},
-		{
-			json.Number("3.14"),
-			true,
-			jsonNumberString,
-		},
-		{
-			func() {},
-			false,
-			jsonInvalid,
-		},
-		{
-			[]interface{}{},
-			true,
-			jsonArray,
-		},
-		{
-			map[string]interface{}{},
-			true,
-			jsonObject,
-		},
-	} {
-		k := jsonTypeSwitch(tc.val)
-		if k != tc.kind {
-			t.Errorf("got %s, want %s", k, tc.kind)
-		}
-	}
-}
+	"github.com/wI2L/jsondiff/internal/engine"
+)
 
 func Test_deepEqualValue(t *testing.T) {
 	for _, tc := range []struct {
@@ -143,18 +61,167 @@ func Test_deepEqual_invalid_type(t *testing.T) {
 	deepEqual(foo{}, nil)
 }
 
-func Test_jsonValueType_String(t *testing.T) {
-	for typ := 0; typ < len(jsonTypeNames); typ++ {
-		s := jsonValueType(typ).String()
-		if s != jsonTypeNames[typ] {
-			t.Errorf("got %q, want %q", s, jsonTypeNames[typ])
+func TestDiffer_equal_floatTolerance(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  []Option
+		src   interface{}
+		tgt   interface{}
+		equal bool
+	}{
+		{"no tolerance", nil, 0.30000000000000004, 0.3, false},
+		{"absolute", []Option{FloatTolerance(1e-9, 0)}, 0.30000000000000004, 0.3, true},
+		{"absolute exceeded", []Option{FloatTolerance(0.1, 0)}, 1.0, 1.2, false},
+		{"relative", []Option{FloatTolerance(0, 0.01)}, 1000.0, 1009.0, true},
+		{"relative exceeded", []Option{FloatTolerance(0, 0.01)}, 1000.0, 1011.0, false},
+		{
+			"nested",
+			[]Option{FloatTolerance(0.5, 0)},
+			map[string]interface{}{"a": []interface{}{1.0, 2.0}},
+			map[string]interface{}{"a": []interface{}{1.4, 2.2}},
+			true,
+		},
+		{
+			"scoped",
+			[]Option{FloatTolerance(0.5, 0, "/a/*")},
+			map[string]interface{}{"a": []interface{}{1.0}, "b": 1.0},
+			map[string]interface{}{"a": []interface{}{1.4}, "b": 1.0},
+			true,
+		},
+		{
+			"out of scope",
+			[]Option{FloatTolerance(0.5, 0, "/a")},
+			map[string]interface{}{"a": 1.0, "b": 1.0},
+			map[string]interface{}{"a": 1.0, "b": 1.4},
+			false,
+		},
+		{
+			"first tolerance that applies",
+			[]Option{FloatTolerance(0, 0, "/a"), FloatTolerance(0.5, 0)},
+			map[string]interface{}{"a": 1.0, "b": 1.0},
+			map[string]interface{}{"a": 1.4, "b": 1.4},
+			false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Differ{}
+			d.applyOpts(tc.opts...)
+
+			if eq := d.equal(engine.Pointer{}, tc.src, tc.tgt); eq != tc.equal {
+				t.Errorf("got %t, want %t", eq, tc.equal)
+			}
+		})
+	}
+}
+
+func TestCompare_floatTolerance(t *testing.T) {
+	src := []byte(`{"a":0.30000000000000004,"b":[1.0,2.0,3.0],"c":[1.0,2.0,3.0],"d":10}`)
+	tgt := []byte(`{"a":0.3,"b":[3.0000001,1.0,2.0],"c":[1.0000001,2.0,5.0],"d":11}`)
+
+	patch, err := CompareJSON(src, tgt, FloatTolerance(1e-6, 0), Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 2 || patch[0].Path != "/c/2" || patch[1].Path != "/d" {
+		t.Errorf("unexpected patch:\n%s", patch.String())
+	}
+	patch, err = CompareJSON(src, tgt, FloatTolerance(1e-6, 0), LCS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The elements of the /b array are equal within the
+	// tolerance, but shifted.
+	want := Patch{
+		{Type: OperationAdd, Path: "/b/0"},
+		{Type: OperationRemove, Path: "/b/3"},
+		{Type: OperationReplace, Path: "/c/2"},
+		{Type: OperationReplace, Path: "/d"},
+	}
+	if len(patch) != len(want) {
+		t.Fatalf("got %d operations, want %d:\n%s", len(patch), len(want), patch.String())
+	}
+	for i, op := range patch {
+		if op.Type != want[i].Type || op.Path != want[i].Path {
+			t.Errorf("op #%d mismatch: got %s %s, want %s %s", i, op.Type, op.Path, want[i].Type, want[i].Path)
 		}
 	}
-	unknownType := jsonValueType(9000)
-	s := unknownType.String()
+}
 
-	const want = "type9000"
-	if s != want {
-		t.Errorf("got %q, want %q", s, want)
+func TestCompare_equalFunc(t *testing.T) {
+	caseInsensitive := func(a, b any) bool {
+		s1, ok1 := a.(string)
+		s2, ok2 := b.(string)
+		return ok1 && ok2 && strings.EqualFold(s1, s2)
+	}
+	trailingSlash := func(a, b any) bool {
+		s1, ok1 := a.(string)
+		s2, ok2 := b.(string)
+		return ok1 && ok2 && strings.TrimSuffix(s1, "/") == strings.TrimSuffix(s2, "/")
+	}
+	for _, tc := range []struct {
+		name  string
+		src   string
+		tgt   string
+		opts  []Option
+		patch []string
+	}{
+		{
+			"exact pointer",
+			`{"kind":"Pod","url":"http://a/"}`,
+			`{"kind":"POD","url":"http://a"}`,
+			[]Option{EqualFunc("/kind", caseInsensitive)},
+			[]string{"replace /url"},
+		},
+		{
+			"pattern",
+			`{"links":["http://a/","http://b"],"kind":"Pod"}`,
+			`{"links":["http://a","http://b/"],"kind":"POD"}`,
+			[]Option{EqualFunc("/links/*", trailingSlash)},
+			[]string{"replace /kind"},
+		},
+		{
+			"different types",
+			`{"a":1,"b":1}`,
+			`{"a":"1","b":"1"}`,
+			[]Option{EqualFunc("/a", func(a, b any) bool {
+				return fmt.Sprint(a) == fmt.Sprint(b)
+			})},
+			[]string{"replace /b"},
+		},
+		{
+			"equivalent",
+			`{"tags":["A","B"]}`,
+			`{"tags":["b","a"]}`,
+			[]Option{Equivalent(), EqualFunc("/tags/*", caseInsensitive)},
+			nil,
+		},
+		{
+			"lcs",
+			`{"tags":["A","B","C"]}`,
+			`{"tags":["b","c"]}`,
+			[]Option{LCS(), EqualFunc("/tags/*", caseInsensitive)},
+			[]string{"remove /tags/0"},
+		},
+		{
+			"factorize",
+			`{"a":"X","b":"Y"}`,
+			`{"b":"Y","c":"x"}`,
+			[]Option{Factorize(), EqualFunc("/**", caseInsensitive)},
+			[]string{"move /c"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			patch, err := CompareJSON([]byte(tc.src), []byte(tc.tgt), tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops := make([]string, 0, len(patch))
+			for _, op := range patch {
+				ops = append(ops, op.Type+" "+op.Path)
+			}
+			if !reflect.DeepEqual(ops, tc.patch) && (len(ops) != 0 || len(tc.patch) != 0) {
+				t.Errorf("got %v, want %v", ops, tc.patch)
+			}
+		})
 	}
 }
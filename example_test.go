@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/wI2L/jsondiff"
 )
@@ -189,6 +191,56 @@ func ExampleIgnores() {
 	// Output:
 }
 
+func ExampleIgnoreFunc() {
+	source := `{"name":"foo","x-request-id":"a3f1","x-trace":true}`
+	target := `{"name":"bar","x-request-id":"c07e"}`
+
+	patch, err := jsondiff.CompareJSON(
+		[]byte(source),
+		[]byte(target),
+		jsondiff.IgnoreFunc(func(path string, _, _ any) bool {
+			return strings.HasPrefix(path, "/x-")
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, op := range patch {
+		fmt.Printf("%s\n", op)
+	}
+	// Output:
+	// {"value":"bar","op":"replace","path":"/name"}
+}
+
+func ExampleEqualFunc() {
+	source := `{"createdAt":"2024-05-01T12:00:00Z","status":"ready"}`
+	target := `{"createdAt":"2024-05-01T14:00:00+02:00","status":"done"}`
+
+	patch, err := jsondiff.CompareJSON(
+		[]byte(source),
+		[]byte(target),
+		jsondiff.EqualFunc("/createdAt", func(a, b any) bool {
+			s1, ok1 := a.(string)
+			s2, ok2 := b.(string)
+			if !ok1 || !ok2 {
+				return false
+			}
+			t1, err1 := time.Parse(time.RFC3339, s1)
+			t2, err2 := time.Parse(time.RFC3339, s2)
+
+			return err1 == nil && err2 == nil && t1.Equal(t2)
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, op := range patch {
+		fmt.Printf("%s\n", op)
+	}
+	// Output:
+	// {"value":"done","op":"replace","path":"/status"}
+}
+
 func ExampleMarshalFunc() {
 	oldPod := createPod()
 	newPod := createPod()
@@ -276,3 +328,40 @@ func ExampleMergePatchJSON() {
 	// Output:
 	// {"b":null,"c":[1,2,3],"d":{"foo":"bar"}}
 }
+
+func ExampleTransform() {
+	a := jsondiff.Patch{
+		{Type: jsondiff.OperationAdd, Path: "/a/0", Value: 0},
+	}
+	b := jsondiff.Patch{
+		{Type: jsondiff.OperationReplace, Path: "/a/1", Value: 20},
+	}
+	ta, tb, err := jsondiff.Transform(a, b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(ta.String())
+	fmt.Println(tb.String())
+	// Output:
+	// {"value":0,"op":"add","path":"/a/0"}
+	// {"value":20,"op":"replace","path":"/a/2"}
+}
+
+func ExampleStreamFunc() {
+	source := []byte(`{"a":[1,2],"b":"foo"}`)
+	target := []byte(`{"a":[1,2,3],"c":true}`)
+
+	enc := jsondiff.NewPatchEncoder(os.Stdout)
+	enc.SetNDJSON(true)
+
+	if _, err := jsondiff.CompareJSON(source, target, jsondiff.StreamFunc(enc.Encode)); err != nil {
+		log.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// {"value":3,"op":"add","path":"/a/-"}
+	// {"op":"remove","path":"/b"}
+	// {"value":true,"op":"add","path":"/c"}
+}
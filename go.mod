@@ -1,15 +1,5 @@
 module github.com/wI2L/jsondiff
 
-go 1.21
-
-require (
-	github.com/tidwall/gjson v1.18.0
-	github.com/tidwall/sjson v1.2.5
-)
-
-require (
-	github.com/tidwall/match v1.1.1 // indirect
-	github.com/tidwall/pretty v1.2.1 // indirect
-)
+go 1.24
 
 retract v0.5.1 // checksum mismatch: git tag has been rewritten. See github.com/wI2L/jsondiff/issues/21
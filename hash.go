@@ -2,13 +2,17 @@ package jsondiff
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"hash/maphash"
 	"math"
 	"slices"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 type hasher struct {
-	mh maphash.Hash
+	mh   maphash.Hash
+	keys []string // reused by nested objects
 }
 
 func (h *hasher) digest(val interface{}, sort bool) uint64 {
@@ -32,6 +36,8 @@ func (h *hasher) hash(i interface{}, sort bool) {
 		var buf [8]byte
 		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
 		_, _ = h.mh.Write(buf[:])
+	case json.Number:
+		h.hashNumber(v)
 	case nil:
 		_ = h.mh.WriteByte('0')
 	case []interface{}:
@@ -42,22 +48,46 @@ func (h *hasher) hash(i interface{}, sort bool) {
 			h.hash(e, sort)
 		}
 	case map[string]interface{}:
-		keys := make([]string, 0, len(v))
-
 		// Extract keys first, and sort them
 		// in lexicographical order.
+		// The keys are appended to a buffer shared
+		// with nested objects, which only use the
+		// space after the keys of their parent.
+		start := len(h.keys)
 		for k := range v {
-			keys = append(keys, k)
+			h.keys = append(h.keys, k)
 		}
+		keys := h.keys[start:]
 		sortStrings(keys)
 
 		for _, k := range keys {
 			_, _ = h.mh.WriteString(k)
 			h.hash(v[k], sort)
 		}
+		clear(keys)
+		h.keys = h.keys[:start]
 	}
 }
 
+// hashNumber hashes the canonical form of the number, so
+// that numbers with the same value have the same digest.
+func (h *hasher) hashNumber(n json.Number) {
+	d, ok := engine.ParseDecimal(string(n))
+	if !ok {
+		_, _ = h.mh.WriteString(string(n))
+		return
+	}
+	if d.Neg {
+		_ = h.mh.WriteByte('-')
+	}
+	_, _ = h.mh.WriteString(d.IntPart)
+	_, _ = h.mh.WriteString(d.FracPart)
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(d.Exp))
+	_, _ = h.mh.Write(buf[:])
+}
+
 func (h *hasher) sortArray(a []interface{}) {
 	h1 := hasher{}
 	h2 := hasher{}
@@ -34,6 +34,26 @@ func Test_digestValue(t *testing.T) {
 	}
 }
 
+func Test_digestNumber(t *testing.T) {
+	for _, tc := range []struct {
+		n1, n2 json.Number
+	}{
+		{"1", "1.0"},
+		{"1", "1.000e0"},
+		{"10", "1E+1"},
+		{"0.001", "1e-3"},
+		{"0", "-0"},
+		{"-1.5", "-15e-1"},
+		{"9007199254740993", "9007199254740993.0"},
+		{"1e99999999999", "1e99999999999"},
+	} {
+		h := hasher{}
+		if h.digest(tc.n1, false) != h.digest(tc.n2, false) {
+			t.Errorf("expected digests of %q and %q to be equal", tc.n1, tc.n2)
+		}
+	}
+}
+
 func BenchmarkHashing(b *testing.B) {
 	if testing.Short() {
 		b.Skip("skipping benchmark in short mode")
@@ -0,0 +1,617 @@
+// Package engine implements the application of JSON Patch
+// operations to decoded JSON documents, and the primitives
+// on JSON values and pointers it relies on. It is shared
+// by the jsondiff and jsonpatch packages.
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidOperation is returned when an operation
+// is malformed, such as an unknown operation type, an
+// invalid pointer, or a move to a child of its origin.
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// The errors wrapped by the failures of the operations,
+// which are exported by the jsonpatch package.
+var (
+	ErrTestFailed   = errors.New("test failed")
+	ErrPathNotFound = errors.New("path not found")
+	ErrTypeMismatch = errors.New("type mismatch")
+)
+
+// Operation types, as defined in RFC 6902 section 4.
+const (
+	OpAdd     = "add"
+	OpReplace = "replace"
+	OpRemove  = "remove"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// An Operation is the part of a JSON Patch operation
+// that is used to apply it to a document.
+type Operation struct {
+	Value any
+	Type  string
+	From  string
+	Path  string
+}
+
+// An Error reports the failure of an operation
+// applied to a document.
+type Error struct {
+	// Err is the cause of the failure. It wraps
+	// ErrInvalidOperation, or one of the errors
+	// exported by the jsonpatch package.
+	Err error
+
+	// Pointer is the location of the document at which
+	// the operation failed, which may be a parent of the
+	// path or the origin of the operation.
+	Pointer string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at %q", e.Err, e.Pointer)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options relax the semantics of RFC 6902 when
+// a patch is applied. The failures of the test
+// operations located at the TestPointers, which
+// may contain wildcard segments, are ignored.
+type Options struct {
+	TestPointers  []string
+	CreateParents bool
+	SkipMissing   bool
+	IgnoreTests   bool
+}
+
+// A Failure reports the failure of the operation
+// at position Index of a patch.
+type Failure struct {
+	Err     error
+	Index   int
+	Pointer string
+}
+
+// An Applier applies the operations of
+// a patch to a decoded JSON document.
+type Applier struct {
+	enc           Encoder
+	testIgnores   map[string]struct{}
+	testGlobs     [][]string
+	createParents bool
+	skipMissing   bool
+	ignoreTests   bool
+}
+
+// NewApplier returns an Applier that
+// uses the options opts.
+func NewApplier(opts Options) *Applier {
+	a := &Applier{
+		createParents: opts.CreateParents,
+		skipMissing:   opts.SkipMissing,
+		ignoreTests:   opts.IgnoreTests,
+	}
+	for _, ptr := range opts.TestPointers {
+		if IsGlob(ptr) {
+			a.testGlobs = append(a.testGlobs, SplitGlob(ptr))
+			continue
+		}
+		if a.testIgnores == nil {
+			a.testIgnores = make(map[string]struct{}, len(opts.TestPointers))
+		}
+		a.testIgnores[LiteralPointer(ptr)] = struct{}{}
+	}
+	return a
+}
+
+// Apply applies the operations to a copy of the document
+// doc, and returns it. If an operation fails, the document
+// is discarded, and the failure is reported.
+func (a *Applier) Apply(doc any, ops []Operation) (any, *Failure, error) {
+	v, err := a.enc.Normalize(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	v = DeepCopy(v)
+
+	for i, op := range ops {
+		if err := a.ApplyOp(&v, op); err != nil {
+			return nil, &Failure{Err: err.Err, Index: i, Pointer: err.Pointer}, nil
+		}
+	}
+	return v, nil, nil
+}
+
+// Normalize returns the JSON representation of v,
+// as defined by the Normalize method of Encoder.
+func (a *Applier) Normalize(v any) (any, error) {
+	return a.enc.Normalize(v)
+}
+
+// ApplyOp applies the operation to the document referenced
+// by doc, which may be replaced entirely.
+func (a *Applier) ApplyOp(doc *any, op Operation) *Error {
+	ptr, err := ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	switch op.Type {
+	case OpAdd:
+		v, err := a.Value(op)
+		if err != nil {
+			return err
+		}
+		if a.createParents {
+			createParents(doc, ptr)
+		}
+		return AddValue(doc, ptr, v)
+	case OpRemove:
+		_, err := RemoveValue(doc, ptr)
+		if err != nil && a.skipMissing && errors.Is(err.Err, ErrPathNotFound) {
+			return nil
+		}
+		return err
+	case OpReplace:
+		v, err := a.Value(op)
+		if err != nil {
+			return err
+		}
+		return replaceValue(doc, ptr, v)
+	case OpMove:
+		from, err := ParsePath(op.From)
+		if err != nil {
+			return err
+		}
+		// https://tools.ietf.org/html/rfc6902#section-4.4
+		// The "from" location MUST NOT be a proper prefix
+		// of the "path" location; i.e., a location cannot
+		// be moved into one of its children.
+		if len(from.Tokens) < len(ptr.Tokens) && slices.Equal(from.Tokens, ptr.Tokens[:len(from.Tokens)]) {
+			return &Error{
+				Err:     fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidOperation),
+				Pointer: op.From,
+			}
+		}
+		v, err := RemoveValue(doc, from)
+		if err != nil {
+			return err
+		}
+		if err := AddValue(doc, ptr, v); err != nil {
+			// Restore the value at its origin, so that
+			// a failed operation leaves the document
+			// unchanged.
+			AddValue(doc, from, v)
+			return err
+		}
+		return nil
+	case OpCopy:
+		from, err := ParsePath(op.From)
+		if err != nil {
+			return err
+		}
+		v, err := GetValue(*doc, from)
+		if err != nil {
+			return err
+		}
+		return AddValue(doc, ptr, DeepCopy(v))
+	case OpTest:
+		want, err := a.Value(op)
+		if err != nil {
+			return err
+		}
+		v, err := GetValue(*doc, ptr)
+		if err == nil && !Equal(v, want) {
+			err = &Error{Err: ErrTestFailed, Pointer: op.Path}
+		}
+		if err != nil && a.isTestIgnored(op.Path) && !errors.Is(err.Err, ErrInvalidOperation) {
+			return nil
+		}
+		return err
+	}
+	return &Error{
+		Err:     fmt.Errorf("%w: unknown operation type %q", ErrInvalidOperation, op.Type),
+		Pointer: op.Path,
+	}
+}
+
+// isTestIgnored returns whether the failure of a test
+// operation located at ptr must be ignored.
+func (a *Applier) isTestIgnored(ptr string) bool {
+	if a.ignoreTests {
+		return true
+	}
+	if _, ok := a.testIgnores[ptr]; ok {
+		return true
+	}
+	for _, g := range a.testGlobs {
+		if MatchGlob(g, ptr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns a copy of the value of the operation,
+// normalized to the types produced by json.Unmarshal.
+func (a *Applier) Value(op Operation) (any, *Error) {
+	v, err := a.enc.Normalize(op.Value)
+	if err != nil {
+		return nil, &Error{
+			Err:     fmt.Errorf("%w: %s", ErrInvalidOperation, err),
+			Pointer: op.Path,
+		}
+	}
+	return DeepCopy(v), nil
+}
+
+// A ParsedPointer represents a parsed JSON Pointer.
+type ParsedPointer struct {
+	Str    string
+	Tokens []string // unescaped tokens
+	Esc    []string // escaped tokens
+}
+
+// ParsePath parses the pointer string s, which
+// is the path or origin of an operation.
+func ParsePath(s string) (ParsedPointer, *Error) {
+	esc, err := ParsePointer(s)
+	if err != nil {
+		return ParsedPointer{}, &Error{
+			Err:     fmt.Errorf("%w: invalid pointer: %s", ErrInvalidOperation, err),
+			Pointer: s,
+		}
+	}
+	p := ParsedPointer{
+		Str:    s,
+		Tokens: make([]string, len(esc)),
+		Esc:    esc,
+	}
+	for i, t := range esc {
+		p.Tokens[i] = rfc6901Unescaper.Replace(t)
+	}
+	return p, nil
+}
+
+// Prefix returns the pointer string of the
+// first n tokens of the path.
+func (p ParsedPointer) Prefix(n int) string {
+	if n >= len(p.Tokens) {
+		return p.Str
+	}
+	var sb strings.Builder
+	for _, t := range p.Esc[:n] {
+		sb.WriteByte(Separator)
+		sb.WriteString(t)
+	}
+	return sb.String()
+}
+
+// JoinPointer returns the pointer made of the
+// concatenation of the unescaped tokens.
+func JoinPointer(tokens ...[]string) ParsedPointer {
+	var (
+		p  ParsedPointer
+		sb strings.Builder
+	)
+	for _, t := range slices.Concat(tokens...) {
+		e := rfc6901Escaper.Replace(t)
+		p.Tokens = append(p.Tokens, t)
+		p.Esc = append(p.Esc, e)
+		sb.WriteByte(Separator)
+		sb.WriteString(e)
+	}
+	p.Str = sb.String()
+
+	return p
+}
+
+func (p ParsedPointer) errorf(n int, err error, format string, args ...any) *Error {
+	if format != "" {
+		err = fmt.Errorf("%w: %s", err, fmt.Sprintf(format, args...))
+	}
+	return &Error{Err: err, Pointer: p.Prefix(n)}
+}
+
+// parentValue returns the container of the value referenced
+// by the path, and a function that replaces it in the
+// document. The path must not be the root pointer.
+func parentValue(doc *any, p ParsedPointer) (any, func(any), *Error) {
+	set := func(v any) { *doc = v }
+	cur := *doc
+
+	for i, tok := range p.Tokens[:len(p.Tokens)-1] {
+		switch c := cur.(type) {
+		case map[string]any:
+			child, ok := c[tok]
+			if !ok {
+				return nil, nil, p.errorf(i+1, ErrPathNotFound, "")
+			}
+			set = func(v any) { c[tok] = v }
+			cur = child
+		case []any:
+			idx, err := arrayIndex(p, i, len(c)-1)
+			if err != nil {
+				return nil, nil, err
+			}
+			set = func(v any) { c[idx] = v }
+			cur = c[idx]
+		default:
+			return nil, nil, p.errorf(i+1, ErrTypeMismatch, "cannot resolve token %q in %s value", tok, TypeOf(cur))
+		}
+	}
+	return cur, set, nil
+}
+
+// createParents creates the missing objects of the document
+// that are parents of the value referenced by the path. A
+// missing array element, or the value of another type, is
+// not replaced.
+func createParents(doc *any, p ParsedPointer) {
+	if len(p.Tokens) == 0 {
+		return
+	}
+	cur := *doc
+	for _, tok := range p.Tokens[:len(p.Tokens)-1] {
+		switch c := cur.(type) {
+		case map[string]any:
+			child, ok := c[tok]
+			if !ok {
+				child = make(map[string]any)
+				c[tok] = child
+			}
+			cur = child
+		case []any:
+			idx, ok := ParseIndex(tok)
+			if !ok || idx >= len(c) {
+				// Let the operation report the error.
+				return
+			}
+			cur = c[idx]
+		default:
+			return
+		}
+	}
+}
+
+// arrayIndex returns the array index represented by the
+// token at position i of the path, which must not exceed
+// max. The "-" token is not accepted.
+func arrayIndex(p ParsedPointer, i, max int) (int, *Error) {
+	tok := p.Tokens[i]
+	if tok == "-" {
+		return 0, p.errorf(i+1, ErrPathNotFound, "index %q refers to a nonexistent element", tok)
+	}
+	idx, ok := ParseIndex(tok)
+	if !ok {
+		return 0, p.errorf(i+1, ErrTypeMismatch, "invalid array index %q", tok)
+	}
+	if idx > max {
+		return 0, p.errorf(i+1, ErrPathNotFound, "index %d out of bounds", idx)
+	}
+	return idx, nil
+}
+
+// ParseIndex parses an array index token, which is
+// either zero or a number without leading zeros.
+func ParseIndex(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	idx, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return idx, true
+}
+
+// GetValue returns the value located at the path.
+func GetValue(doc any, p ParsedPointer) (any, *Error) {
+	cur := doc
+	for i, tok := range p.Tokens {
+		switch c := cur.(type) {
+		case map[string]any:
+			child, ok := c[tok]
+			if !ok {
+				return nil, p.errorf(i+1, ErrPathNotFound, "")
+			}
+			cur = child
+		case []any:
+			idx, err := arrayIndex(p, i, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[idx]
+		default:
+			return nil, p.errorf(i+1, ErrTypeMismatch, "cannot resolve token %q in %s value", tok, TypeOf(cur))
+		}
+	}
+	return cur, nil
+}
+
+// AddValue adds the value v at the path, either by inserting
+// it in an array, or by adding or replacing an object
+// member.
+func AddValue(doc *any, p ParsedPointer, v any) *Error {
+	if len(p.Tokens) == 0 {
+		*doc = v
+		return nil
+	}
+	cont, set, err := parentValue(doc, p)
+	if err != nil {
+		return err
+	}
+	last := len(p.Tokens) - 1
+	tok := p.Tokens[last]
+
+	switch c := cont.(type) {
+	case map[string]any:
+		c[tok] = v
+	case []any:
+		if tok == "-" {
+			set(append(c, v))
+			return nil
+		}
+		idx, err := arrayIndex(p, last, len(c))
+		if err != nil {
+			return err
+		}
+		set(slices.Insert(c, idx, v))
+	default:
+		return p.errorf(last+1, ErrTypeMismatch, "cannot add a member to %s value", TypeOf(cont))
+	}
+	return nil
+}
+
+// RemoveValue removes the value located at the path,
+// and returns it.
+func RemoveValue(doc *any, p ParsedPointer) (any, *Error) {
+	if len(p.Tokens) == 0 {
+		v := *doc
+		*doc = nil
+		return v, nil
+	}
+	cont, set, err := parentValue(doc, p)
+	if err != nil {
+		return nil, err
+	}
+	last := len(p.Tokens) - 1
+	tok := p.Tokens[last]
+
+	switch c := cont.(type) {
+	case map[string]any:
+		v, ok := c[tok]
+		if !ok {
+			return nil, p.errorf(last+1, ErrPathNotFound, "")
+		}
+		delete(c, tok)
+		return v, nil
+	case []any:
+		idx, err := arrayIndex(p, last, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		v := c[idx]
+		set(slices.Delete(c, idx, idx+1))
+		return v, nil
+	default:
+		return nil, p.errorf(last+1, ErrTypeMismatch, "cannot remove a member of %s value", TypeOf(cont))
+	}
+}
+
+// replaceValue replaces the value located at the path,
+// which must exist, with the value v.
+func replaceValue(doc *any, p ParsedPointer, v any) *Error {
+	if len(p.Tokens) == 0 {
+		*doc = v
+		return nil
+	}
+	cont, _, err := parentValue(doc, p)
+	if err != nil {
+		return err
+	}
+	last := len(p.Tokens) - 1
+	tok := p.Tokens[last]
+
+	switch c := cont.(type) {
+	case map[string]any:
+		if _, ok := c[tok]; !ok {
+			return p.errorf(last+1, ErrPathNotFound, "")
+		}
+		c[tok] = v
+	case []any:
+		idx, err := arrayIndex(p, last, len(c)-1)
+		if err != nil {
+			return err
+		}
+		c[idx] = v
+	default:
+		return p.errorf(last+1, ErrTypeMismatch, "cannot replace a member of %s value", TypeOf(cont))
+	}
+	return nil
+}
+
+// DeepCopy returns a deep copy of the JSON value v.
+func DeepCopy(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for k, e := range val {
+			m[k] = DeepCopy(e)
+		}
+		return m
+	case []any:
+		a := make([]any, len(val))
+		for i, e := range val {
+			a[i] = DeepCopy(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// Equal returns whether the JSON values v1 and v2 are
+// equal, as defined by the test operation of RFC 6902.
+// Numbers are compared by value, even if one is represented
+// by a json.Number and the other one by a float64.
+func Equal(v1, v2 any) bool {
+	switch t1 := v1.(type) {
+	case map[string]any:
+		t2, ok := v2.(map[string]any)
+		if !ok || len(t1) != len(t2) {
+			return false
+		}
+		for k, e1 := range t1 {
+			e2, ok := t2[k]
+			if !ok || !Equal(e1, e2) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		t2, ok := v2.([]any)
+		if !ok || len(t1) != len(t2) {
+			return false
+		}
+		for i := range t1 {
+			if !Equal(t1[i], t2[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		switch t2 := v2.(type) {
+		case json.Number:
+			return EqualNumber(t1, t2)
+		case float64:
+			return EqualNumber(t1, json.Number(strconv.FormatFloat(t2, 'g', -1, 64)))
+		}
+		return false
+	case float64:
+		if t2, ok := v2.(json.Number); ok {
+			return Equal(t2, t1)
+		}
+		return v1 == v2
+	default:
+		return v1 == v2
+	}
+}
@@ -0,0 +1,55 @@
+package engine
+
+// A Result reports the outcome of an operation
+// checked against a document.
+type Result struct {
+	Failure *Failure
+	Pointer string
+	Value   any
+	Found   bool
+}
+
+// Check reports whether each operation can be applied to
+// the document. A failed operation is skipped, and the
+// following operations are checked against the document
+// patched by the previous operations that succeeded.
+func (a *Applier) Check(doc any, ops []Operation) ([]Result, error) {
+	v, err := a.enc.Normalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	v = DeepCopy(v)
+
+	res := make([]Result, len(ops))
+	for i, op := range ops {
+		r := Result{Pointer: op.Path}
+		r.Value, r.Found = LookupValue(v, op.Path)
+
+		// A failed operation leaves the document unchanged,
+		// hence the value at the location of the failure
+		// can be looked up afterward.
+		if err := a.ApplyOp(&v, op); err != nil {
+			r.Failure = &Failure{Err: err.Err, Index: i, Pointer: err.Pointer}
+			if err.Pointer != op.Path {
+				r.Pointer = err.Pointer
+				r.Value, r.Found = LookupValue(v, err.Pointer)
+			}
+		}
+		res[i] = r
+	}
+	return res, nil
+}
+
+// LookupValue returns a copy of the value located
+// at the pointer s, and whether it exists.
+func LookupValue(doc any, s string) (any, bool) {
+	ptr, err := ParsePath(s)
+	if err != nil {
+		return nil, false
+	}
+	v, err := GetValue(doc, ptr)
+	if err != nil {
+		return nil, false
+	}
+	return DeepCopy(v), true
+}
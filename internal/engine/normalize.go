@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// Normalize returns the JSON representation of v, which
+// may be composed of any Go scalar, slice, array and map
+// types, as well as pointers to them. Unlike Encode, the
+// values that already have the types produced by the
+// json.Unmarshal function are returned as is, and arrays
+// and objects are only copied if one of their elements
+// is converted. Integers that a float64 cannot represent
+// exactly are converted to json.Number, to keep their
+// precision. Struct types are not supported.
+func (e *Encoder) Normalize(v any) (interface{}, error) {
+	e.ptr.Reset()
+	nv, _, err := e.native(v)
+	return nv, err
+}
+
+// native returns the JSON representation of v, and
+// whether it differs from v.
+func (e *Encoder) native(v any) (interface{}, bool, error) {
+	switch val := v.(type) {
+	case nil, bool, float64, string, json.Number:
+		return v, false, nil
+	case []interface{}:
+		return e.nativeSlice(val)
+	case map[string]interface{}:
+		return e.nativeMap(val)
+	case map[interface{}]interface{}:
+		// Produced by some YAML decoders.
+		return e.nativeAnyMap(val)
+	// Fast paths for common types, without reflection.
+	case int:
+		return NativeInt(int64(val)), true, nil
+	case int64:
+		return NativeInt(val), true, nil
+	case int32:
+		return float64(val), true, nil
+	case uint:
+		return nativeUint(uint64(val)), true, nil
+	case uint64:
+		return nativeUint(val), true, nil
+	case float32:
+		f, err := e.float(float64(val), 32, false)
+		return f, true, err
+	case []string:
+		if val == nil {
+			return nil, true, nil
+		}
+		arr := make([]interface{}, len(val))
+		for i, s := range val {
+			arr[i] = s
+		}
+		return arr, true, nil
+	case map[string]string:
+		if val == nil {
+			return nil, true, nil
+		}
+		obj := make(map[string]interface{}, len(val))
+		for k, s := range val {
+			obj[k] = s
+		}
+		return obj, true, nil
+	}
+	return e.nativeValue(reflect.ValueOf(v))
+}
+
+func (e *Encoder) nativeSlice(s []interface{}) (interface{}, bool, error) {
+	var arr []interface{}
+
+	ptr := e.ptr
+	for i, v := range s {
+		e.ptr = ptr
+		e.ptr.AppendIndex(i)
+		nv, changed, err := e.native(v)
+		if err != nil {
+			return nil, false, err
+		}
+		if changed && arr == nil {
+			// Copy the slice on the first conversion
+			// of an element, to leave the input intact.
+			arr = make([]interface{}, len(s))
+			copy(arr, s)
+		}
+		if arr != nil {
+			arr[i] = nv
+		}
+	}
+	e.ptr = ptr
+
+	if arr == nil {
+		return s, false, nil
+	}
+	return arr, true, nil
+}
+
+func (e *Encoder) nativeMap(m map[string]interface{}) (interface{}, bool, error) {
+	var obj map[string]interface{}
+
+	ptr := e.ptr
+	for k, v := range m {
+		e.ptr = ptr
+		e.ptr.AppendKey(k)
+		nv, changed, err := e.native(v)
+		if err != nil {
+			return nil, false, err
+		}
+		if changed && obj == nil {
+			obj = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				obj[k] = v
+			}
+		}
+		if obj != nil {
+			obj[k] = nv
+		}
+	}
+	e.ptr = ptr
+
+	if obj == nil {
+		return m, false, nil
+	}
+	return obj, true, nil
+}
+
+func (e *Encoder) nativeAnyMap(m map[interface{}]interface{}) (interface{}, bool, error) {
+	if m == nil {
+		return nil, true, nil
+	}
+	obj := make(map[string]interface{}, len(m))
+
+	ptr := e.ptr
+	for k, v := range m {
+		e.ptr = ptr
+		key, err := e.nativeKey(reflect.ValueOf(k))
+		if err != nil {
+			return nil, false, err
+		}
+		e.ptr.AppendKey(key)
+		nv, _, err := e.native(v)
+		if err != nil {
+			return nil, false, err
+		}
+		obj[key] = nv
+	}
+	e.ptr = ptr
+
+	return obj, true, nil
+}
+
+// nativeValue returns the JSON representation of
+// the value v using reflection.
+func (e *Encoder) nativeValue(v reflect.Value) (interface{}, bool, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NativeInt(v.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return nativeUint(v.Uint()), true, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := e.float(v.Float(), v.Type().Bits(), false)
+		return f, true, err
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, true, nil
+		}
+		nv, _, err := e.native(v.Elem().Interface())
+		return nv, true, err
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, true, nil
+		}
+		if isByteSlice(v.Type()) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), true, nil
+		}
+		fallthrough
+	case reflect.Array:
+		arr := make([]interface{}, v.Len())
+
+		ptr := e.ptr
+		for i := range arr {
+			e.ptr = ptr
+			e.ptr.AppendIndex(i)
+			nv, _, err := e.native(v.Index(i).Interface())
+			if err != nil {
+				return nil, false, err
+			}
+			arr[i] = nv
+		}
+		e.ptr = ptr
+
+		return arr, true, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, true, nil
+		}
+		obj := make(map[string]interface{}, v.Len())
+
+		ptr := e.ptr
+		it := v.MapRange()
+		for it.Next() {
+			e.ptr = ptr
+			key, err := e.nativeKey(it.Key())
+			if err != nil {
+				return nil, false, err
+			}
+			e.ptr.AppendKey(key)
+			nv, _, err := e.native(it.Value().Interface())
+			if err != nil {
+				return nil, false, err
+			}
+			obj[key] = nv
+		}
+		e.ptr = ptr
+
+		return obj, true, nil
+	}
+	if !v.IsValid() {
+		return nil, true, nil
+	}
+	return nil, false, e.errorf("invalid json type %s", v.Type())
+}
+
+// maxExactInt is the largest magnitude of the
+// integers that a float64 represents exactly.
+const maxExactInt = 1 << 53
+
+// NativeInt returns the JSON representation of the
+// integer i, as a json.Number if a float64 cannot
+// represent it exactly.
+func NativeInt(i int64) interface{} {
+	if i >= -maxExactInt && i <= maxExactInt {
+		return float64(i)
+	}
+	return json.Number(strconv.FormatInt(i, 10))
+}
+
+// nativeUint is like nativeInt, for unsigned integers.
+func nativeUint(u uint64) interface{} {
+	if u <= maxExactInt {
+		return float64(u)
+	}
+	return json.Number(strconv.FormatUint(u, 10))
+}
+
+// nativeKey returns the JSON object member name that
+// represents the map key k. Only string and integer
+// keys are supported.
+func (e *Encoder) nativeKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	if !k.IsValid() {
+		return "", e.errorf("invalid map key <nil>")
+	}
+	return "", e.errorf("invalid map key type %s", k.Type())
+}
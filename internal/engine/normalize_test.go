@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_encoder_normalize(t *testing.T) {
+	type (
+		name   string
+		labels map[string]int
+	)
+	for _, tc := range []struct {
+		name string
+		val  any
+		want any
+	}{
+		{"nil", nil, nil},
+		{"scalars", []any{1, int8(2), uint16(3), float32(0.1), name("a"), true}, []any{1.0, 2.0, 3.0, 0.1, "a", true}},
+		{"number", json.Number("1.5"), json.Number("1.5")},
+		{
+			"large integers",
+			[]any{int64(1 << 53), int64(1<<53 + 1), -1<<53 - 1, uint64(1<<64 - 1), labels{"a": 1<<53 + 1}},
+			[]any{9007199254740992.0, json.Number("9007199254740993"), json.Number("-9007199254740993"), json.Number("18446744073709551615"), map[string]any{"a": json.Number("9007199254740993")}},
+		},
+		{"string slice", []string{"a", "b"}, []any{"a", "b"}},
+		{"nil string slice", []string(nil), nil},
+		{"int array", [2]int{1, 2}, []any{1.0, 2.0}},
+		{"bytes", []byte("foo"), "Zm9v"},
+		{"string map", map[string]string{"a": "b"}, map[string]any{"a": "b"}},
+		{"named map", labels{"a": 1}, map[string]any{"a": 1.0}},
+		{"int keys", map[int]bool{-1: true}, map[string]any{"-1": true}},
+		{"pointer", &[]int{1}, []any{1.0}},
+		{
+			"yaml",
+			map[interface{}]interface{}{
+				"a": []interface{}{map[interface{}]interface{}{"b": 1}},
+				1:   "c",
+			},
+			map[string]any{
+				"a": []any{map[string]any{"b": 1.0}},
+				"1": "c",
+			},
+		},
+		{
+			"nested",
+			map[string]any{"a": []any{"x", map[string]any{"b": []string{"y"}}}},
+			map[string]any{"a": []any{"x", map[string]any{"b": []any{"y"}}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var e Encoder
+			got, err := e.Normalize(tc.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_encoder_normalize_noCopy(t *testing.T) {
+	src := map[string]any{
+		"a": []any{1.0, "b", map[string]any{"c": nil}},
+		"d": map[string]any{"e": 1},
+	}
+	var e Encoder
+	v, err := e.Normalize(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := v.(map[string]any)
+
+	// The object is copied because of the conversion of
+	// one of its members, but the unconverted members are
+	// shared with the input value.
+	if reflect.ValueOf(obj).UnsafePointer() == reflect.ValueOf(src).UnsafePointer() {
+		t.Errorf("expected a copy of the root object")
+	}
+	if reflect.ValueOf(obj["a"]).UnsafePointer() != reflect.ValueOf(src["a"]).UnsafePointer() {
+		t.Errorf("expected array /a to be used as is")
+	}
+	if src["d"].(map[string]any)["e"] != 1 {
+		t.Errorf("input value was modified")
+	}
+	v, err = e.Normalize(src["a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(v).UnsafePointer() != reflect.ValueOf(src["a"]).UnsafePointer() {
+		t.Errorf("expected native array to be used as is")
+	}
+}
+
+func Test_encoder_normalize_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		val  any
+		ptr  string
+	}{
+		{"struct", map[string]any{"a": []any{1, struct{}{}}}, "/a/1"},
+		{"func", map[interface{}]interface{}{"a/b": func() {}}, "/a~1b"},
+		{"map key", map[string]any{"a": map[interface{}]interface{}{1.5: 1}}, "/a"},
+		{"channel", []chan int{nil}, "/0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var e Encoder
+			_, err := e.Normalize(tc.val)
+			if err == nil {
+				t.Fatal("expected non-nil error")
+			}
+			if !strings.HasSuffix(err.Error(), "at \""+tc.ptr+"\"") {
+				t.Errorf("error %q does not name pointer %q", err, tc.ptr)
+			}
+		})
+	}
+}
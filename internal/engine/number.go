@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Decimal represents a JSON number in canonical form,
+// as the digits of its significand, without leading or
+// trailing zeros, multiplied by a power of ten. The
+// digits are split in two parts, to avoid allocations.
+type Decimal struct {
+	IntPart  string // digits of the integer part
+	FracPart string // digits of the fractional part
+	Exp      int
+	Neg      bool
+}
+
+// ParseDecimal parses the JSON number s in canonical
+// form. It reports false if s is not a valid number, or
+// if its exponent overflows.
+func ParseDecimal(s string) (Decimal, bool) {
+	var d Decimal
+
+	if strings.HasPrefix(s, "-") {
+		d.Neg = true
+		s = s[1:]
+	}
+	i := digits(s)
+	if i == 0 {
+		return d, false
+	}
+	d.IntPart, s = s[:i], s[i:]
+
+	if strings.HasPrefix(s, ".") {
+		i = digits(s[1:])
+		if i == 0 {
+			return d, false
+		}
+		d.FracPart, s = s[1:i+1], s[i+1:]
+	}
+	if len(s) != 0 {
+		if s[0] != 'e' && s[0] != 'E' {
+			return d, false
+		}
+		s = s[1:]
+		if len(s) != 0 && s[0] == '+' {
+			s = s[1:]
+		}
+		exp, err := strconv.Atoi(s)
+		if err != nil || exp < -maxExp || exp > maxExp {
+			return d, false
+		}
+		d.Exp = exp
+	}
+	// The value is the integer formed by the digits of
+	// both parts, multiplied by 10^(Exp-len(FracPart)).
+	d.Exp -= len(d.FracPart)
+
+	// Remove trailing zeros of the significand.
+	n := len(d.FracPart)
+	d.FracPart = strings.TrimRight(d.FracPart, "0")
+	d.Exp += n - len(d.FracPart)
+	if d.FracPart == "" {
+		n = len(d.IntPart)
+		d.IntPart = strings.TrimRight(d.IntPart, "0")
+		d.Exp += n - len(d.IntPart)
+	}
+	// Remove leading zeros of the significand.
+	d.IntPart = strings.TrimLeft(d.IntPart, "0")
+	if d.IntPart == "" {
+		d.FracPart = strings.TrimLeft(d.FracPart, "0")
+	}
+	if d.IntPart == "" && d.FracPart == "" {
+		// Zero, regardless of sign and exponent.
+		return Decimal{}, true
+	}
+	return d, true
+}
+
+// maxExp is the maximum absolute value of the exponent
+// of a number that can be parsed, which guarantees that
+// the adjusted exponent of the canonical form does not
+// overflow.
+const maxExp = 1 << 30
+
+// digits returns the number of leading decimal
+// digits of s.
+func digits(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func (d Decimal) equal(o Decimal) bool {
+	if d.Neg != o.Neg || d.Exp != o.Exp {
+		return false
+	}
+	if len(d.IntPart)+len(d.FracPart) != len(o.IntPart)+len(o.FracPart) {
+		return false
+	}
+	// Compare the concatenation of both parts.
+	a1, a2, b1, b2 := d.IntPart, d.FracPart, o.IntPart, o.FracPart
+	for len(a1)+len(a2) != 0 {
+		if len(a1) == 0 {
+			a1, a2 = a2, ""
+		}
+		if len(b1) == 0 {
+			b1, b2 = b2, ""
+		}
+		n := min(len(a1), len(b1))
+		if a1[:n] != b1[:n] {
+			return false
+		}
+		a1, b1 = a1[n:], b1[n:]
+	}
+	return true
+}
+
+// EqualNumber returns whether two JSON numbers have the
+// same numeric value, which is compared exactly. Numbers
+// that cannot be parsed are compared as strings.
+func EqualNumber(n1, n2 json.Number) bool {
+	if n1 == n2 {
+		return true
+	}
+	d1, ok1 := ParseDecimal(string(n1))
+	d2, ok2 := ParseDecimal(string(n2))
+	if !ok1 || !ok2 {
+		return false
+	}
+	return d1.equal(d2)
+}
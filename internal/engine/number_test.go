@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEqualNumber(t *testing.T) {
+	for _, tc := range []struct {
+		n1, n2 json.Number
+		equal  bool
+	}{
+		{"1", "1", true},
+		{"1", "1.0", true},
+		{"1", "1.000e0", true},
+		{"10", "1e1", true},
+		{"10", "1E+1", true},
+		{"0.001", "1e-3", true},
+		{"123.456", "1.23456e2", true},
+		{"0", "-0", true},
+		{"0", "0.0e10", true},
+		{"-1.5", "-15e-1", true},
+		{"1", "-1", false},
+		{"1", "2", false},
+		{"1", "10", false},
+		{"0.1", "0.01", false},
+		{"12.34", "1.234", false},
+		{"9007199254740993", "9007199254740992", false},
+		{"9007199254740993", "9007199254740993.0", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"1.", "1", false},
+		{"1e99999999999", "1e99999999999", true},
+	} {
+		if eq := EqualNumber(tc.n1, tc.n2); eq != tc.equal {
+			t.Errorf("EqualNumber(%q, %q): got %t, want %t", tc.n1, tc.n2, eq, tc.equal)
+		}
+	}
+}
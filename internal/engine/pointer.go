@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	Separator    = '/'
+	escapeSlash  = "~1"
+	escapeTilde  = "~0"
+	emptyPointer = ""
+)
+
+var (
+	// rfc6901Escaper is a replacer that escapes a JSON Pointer string
+	// in compliance with the JavaScript Object Notation Pointer syntax.
+	// https://tools.ietf.org/html/rfc6901
+	rfc6901Escaper = strings.NewReplacer("~", escapeTilde, "/", escapeSlash)
+
+	// rfc6901Unescaper is a replacer that unescape a JSON Pointer string.
+	rfc6901Unescaper = strings.NewReplacer(escapeTilde, "~", escapeSlash, "/")
+)
+
+type segment struct {
+	key string
+	idx int
+}
+
+// Pointer represents an RFC 6901 JSON Pointer.
+type Pointer struct {
+	buf    []byte
+	base   segment
+	prev   segment
+	sep    int
+	depth  int // number of segments
+	sdepth int // depth at snapshot
+}
+
+// NewPointer returns a pointer initialized
+// with the escaped pointer string s.
+func NewPointer(s string) Pointer {
+	return Pointer{buf: []byte(s)}
+}
+
+func (p *Pointer) Clone() Pointer {
+	return *p
+}
+
+func (p *Pointer) Copy() string {
+	return string(p.buf)
+}
+
+func (p *Pointer) String() string {
+	return *(*string)(unsafe.Pointer(&p.buf))
+}
+
+func (p *Pointer) IsRoot() bool {
+	return len(p.buf) == 0
+}
+
+func (p *Pointer) AppendKey(key string) {
+	p.depth++
+	p.buf = append(p.buf, Separator)
+	p.base = segment{key: key}
+	p.AppendEscapeKey(key)
+}
+
+func (p *Pointer) AppendIndex(idx int) {
+	p.depth++
+	p.buf = append(p.buf, Separator)
+	p.buf = strconv.AppendInt(p.buf, int64(idx), 10)
+	p.base = segment{idx: idx}
+}
+
+// Depth returns the number of segments of the pointer.
+func (p *Pointer) Depth() int {
+	return p.depth
+}
+
+// Key returns the last segment of the pointer,
+// if it was appended with AppendKey.
+func (p *Pointer) Key() string {
+	return p.base.key
+}
+
+// Index returns the last segment of the pointer,
+// if it was appended with AppendIndex.
+func (p *Pointer) Index() int {
+	return p.base.idx
+}
+
+func (p *Pointer) Snapshot() {
+	p.sep = len(p.buf)
+	p.prev = p.base
+	p.sdepth = p.depth
+}
+
+func (p *Pointer) Rewind() {
+	p.buf = p.buf[:p.sep]
+	p.base = p.prev
+	p.depth = p.sdepth
+}
+
+func (p *Pointer) Reset() {
+	p.buf = p.buf[:0]
+	p.sep = 0
+	p.depth = 0
+	p.sdepth = 0
+}
+
+func (p *Pointer) AppendEscapeKey(k string) {
+	for _, c := range []byte(k) {
+		switch c {
+		case '/':
+			p.buf = append(p.buf, escapeSlash...)
+		case '~':
+			p.buf = append(p.buf, escapeTilde...)
+		default:
+			p.buf = append(p.buf, c)
+		}
+	}
+}
+
+var (
+	errLeadingSlash             = errors.New("no leading slash")
+	errIncompleteEscapeSequence = errors.New("incomplete escape sequence")
+	errInvalidEscapeSequence    = errors.New("invalid escape sequence")
+)
+
+func ParsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	a := []rune(s)
+
+	if len(a) > 0 && a[0] != '/' {
+		return nil, errLeadingSlash
+	}
+	var tokens []string
+
+	ls := 0
+	for i, r := range a {
+		switch {
+		case r == '/':
+			if i != 0 {
+				tokens = append(tokens, string(a[ls+1:i]))
+			}
+			if i == len(a)-1 {
+				// Last char is a '/', next fragment is an empty string.
+				tokens = append(tokens, "")
+				break
+			}
+			ls = i
+		case r == '~':
+			if i == len(a)-1 {
+				return nil, errIncompleteEscapeSequence
+			}
+			if a[i+1] != '0' && a[i+1] != '1' {
+				return nil, errInvalidEscapeSequence
+			}
+		case i == len(a)-1:
+			// End of string, accumulate from last separator.
+			tokens = append(tokens, string(a[ls+1:]))
+		}
+	}
+	return tokens, nil
+}
+
+const (
+	globSegment    = "*"
+	globAnySegment = "**"
+)
+
+// literalSegment returns the segment s of a pattern, without
+// the backslash that escapes a literal "*" or "**" segment.
+func literalSegment(s string) string {
+	if s == `\*` || s == `\**` {
+		return s[1:]
+	}
+	return s
+}
+
+// LiteralPointer returns the pointer string s, which is
+// not a pattern, without the escapes of its segments.
+func LiteralPointer(s string) string {
+	if !strings.Contains(s, `\*`) {
+		return s
+	}
+	segs := SplitGlob(s)
+	for i, seg := range segs {
+		segs[i] = literalSegment(seg)
+	}
+	return string(Separator) + strings.Join(segs, string(Separator))
+}
+
+// IsGlob returns whether the pointer string contains
+// at least one wildcard segment.
+func IsGlob(s string) bool {
+	for _, seg := range SplitGlob(s) {
+		if seg == globSegment || seg == globAnySegment {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitGlob splits a pointer pattern into its raw,
+// escaped segments.
+func SplitGlob(s string) []string {
+	if s == "" || s[0] != Separator {
+		return nil
+	}
+	return strings.Split(s[1:], string(Separator))
+}
+
+// MatchGlob returns whether the escaped pointer string
+// ptr matches the pattern segments. A "*" segment matches
+// any single segment, and a "**" segment matches zero
+// or more segments. The escaped "\*" and "\**" segments
+// match these literal segments.
+func MatchGlob(pattern []string, ptr string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == globAnySegment {
+			// Try to match the rest of the pattern
+			// with an increasing number of segments
+			// consumed by the wildcard.
+			for {
+				if MatchGlob(pattern[1:], ptr) {
+					return true
+				}
+				if ptr == "" {
+					return false
+				}
+				_, ptr = nextSegment(ptr)
+			}
+		}
+		if ptr == "" {
+			return false
+		}
+		var seg string
+		seg, ptr = nextSegment(ptr)
+
+		if pattern[0] != globSegment && literalSegment(pattern[0]) != seg {
+			return false
+		}
+		pattern = pattern[1:]
+	}
+	return ptr == ""
+}
+
+// nextSegment returns the first segment of the non-empty
+// pointer string, and the remainder of the pointer.
+func nextSegment(ptr string) (string, string) {
+	ptr = ptr[1:] // skip leading separator
+	if i := strings.IndexByte(ptr, Separator); i != -1 {
+		return ptr[:i], ptr[i:]
+	}
+	return ptr, emptyPointer
+}
@@ -1,4 +1,4 @@
-package jsondiff
+package engine
 
 import (
 	"errors"
@@ -6,7 +6,7 @@ import (
 	"testing"
 )
 
-func Test_parsePointer(t *testing.T) {
+func Test_ParsePointer(t *testing.T) {
 	for _, tc := range []struct {
 		ptr    string
 		valid  bool
@@ -147,7 +147,7 @@ func Test_parsePointer(t *testing.T) {
 			nil,
 		},
 	} {
-		tokens, err := parsePointer(tc.ptr)
+		tokens, err := ParsePointer(tc.ptr)
 		if tc.valid && err != nil {
 			t.Errorf("expected valid pointer, got error: %q", err)
 		}
@@ -180,16 +180,53 @@ func TestPointer_escapeKey(t *testing.T) {
 			`🔥🚒🧯`,
 		},
 	} {
-		p := pointer{
+		p := Pointer{
 			buf: make([]byte, 0, len(tc.key)*2),
 		}
-		p.appendEscapeKey(tc.key)
-		if s := p.copy(); s != tc.esc {
+		p.AppendEscapeKey(tc.key)
+		if s := p.Copy(); s != tc.esc {
 			t.Errorf("got %q, want %q", s, tc.esc)
 		}
 	}
 }
 
+func Test_MatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		ptr     string
+		match   bool
+	}{
+		{"/a/*", "/a/b", true},
+		{"/a/*", "/a", false},
+		{"/a/*", "/a/b/c", false},
+		{"/*/b", "/0/b", true},
+		{"/*/b", "/0/c", false},
+		{"/*", "", false},
+		{"/a/**", "/a", true},
+		{"/a/**", "/a/b/c/d", true},
+		{"/a/**", "/b/c", false},
+		{"/**", "", true},
+		{"/**/c", "/c", true},
+		{"/**/c", "/a/b/c", true},
+		{"/**/c", "/a/b/c/d", false},
+		{"/items/*/metadata/**", "/items/12/metadata", true},
+		{"/items/*/metadata/**", "/items/12/metadata/a~1b/0", true},
+		{"/items/*/metadata/**", "/items/metadata", false},
+		{"/a~1b/*", "/a~1b/c", true},
+		{"/a/*/", "/a/b/", true},
+		{"/a/*/", "/a/b", false},
+		{`/a/\*`, "/a/*", true},
+		{`/a/\*`, "/a/b", false},
+		{`/a/\**`, "/a/**", true},
+		{`/a/\**`, "/a/b/c", false},
+		{`/*/\*/**`, "/a/*/b", true},
+	} {
+		if m := MatchGlob(SplitGlob(tc.pattern), tc.ptr); m != tc.match {
+			t.Errorf("MatchGlob(%q, %q): got %t, want %t", tc.pattern, tc.ptr, m, tc.match)
+		}
+	}
+}
+
 func BenchmarkEscapeKey(b *testing.B) {
 	if testing.Short() {
 		b.Skip()
@@ -197,16 +234,16 @@ func BenchmarkEscapeKey(b *testing.B) {
 	const key = "a/b~x~1!~0"
 
 	b.Run("strings.Replacer", func(b *testing.B) {
-		p := pointer{buf: make([]byte, 0, len(key)*2)}
+		p := Pointer{buf: make([]byte, 0, len(key)*2)}
 		for b.Loop() {
 			p.buf = append(p.buf, rfc6901Escaper.Replace(key)...)
 			p.buf = p.buf[:0]
 		}
 	})
 	b.Run("appendEscapeKey", func(b *testing.B) {
-		p := pointer{buf: make([]byte, 0, len(key)*2)}
+		p := Pointer{buf: make([]byte, 0, len(key)*2)}
 		for b.Loop() {
-			p.appendEscapeKey(key)
+			p.AppendEscapeKey(key)
 			p.buf = p.buf[:0]
 		}
 	})
@@ -0,0 +1,655 @@
+package engine
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	marshalerType     = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	isZeroerType      = reflect.TypeFor[isZeroer]()
+	numberType        = reflect.TypeFor[json.Number]()
+)
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+// startDetectingCyclesAfter is the nesting level of
+// pointers, maps and slices after which the encoder
+// starts to detect cycles, as encoding/json does.
+const startDetectingCyclesAfter = 1000
+
+// An Encoder converts Go values to the values that would
+// be produced by json.Unmarshal into an empty interface
+// from their JSON representation, following the encoding
+// rules of the encoding/json package, without marshaling
+// them to bytes.
+type Encoder struct {
+	ptr      Pointer
+	ptrLevel int
+	ptrSeen  map[any]struct{}
+}
+
+// Encode returns the JSON representation of v.
+func (e *Encoder) Encode(v any) (interface{}, error) {
+	e.ptr.Reset()
+	return e.value(reflect.ValueOf(v), false)
+}
+
+func (e *Encoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("jsondiff: %s at %q", fmt.Sprintf(format, args...), e.ptr.String())
+}
+
+// value returns the JSON representation of v. If quoted is
+// true, scalar values are encoded as a JSON string, as with
+// the "string" option of a struct field tag.
+func (e *Encoder) value(v reflect.Value, quoted bool) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	t := v.Type()
+
+	// Marshalers take precedence over the kind of the value.
+	// Methods with a pointer receiver are only used if the
+	// value is addressable, like the encoding/json package.
+	if t.Implements(marshalerType) {
+		return e.marshaler(v)
+	}
+	if t.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(marshalerType) {
+		return e.marshaler(v.Addr())
+	}
+	if t.Implements(textMarshalerType) {
+		return e.textMarshaler(v)
+	}
+	if t.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(textMarshalerType) {
+		return e.textMarshaler(v.Addr())
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if quoted {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if quoted {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return e.float(v.Float(), t.Bits(), quoted)
+	case reflect.String:
+		if t == numberType {
+			return e.number(json.Number(v.String()), quoted)
+		}
+		if quoted {
+			// The string is encoded as a JSON string,
+			// which is itself encoded as a string.
+			b, err := json.Marshal(v.String())
+			if err != nil {
+				return nil, e.errorf("%s", err)
+			}
+			return string(b), nil
+		}
+		return validString(v.String()), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return e.value(v.Elem(), false)
+	case reflect.Struct:
+		return e.object(v)
+	case reflect.Map:
+		return e.mapObject(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if isByteSlice(t) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		key := struct {
+			ptr any
+			len int
+		}{v.UnsafePointer(), v.Len()}
+		if err := e.enter(v, key); err != nil {
+			return nil, err
+		}
+		arr, err := e.array(v)
+		e.leave(key)
+		return arr, err
+	case reflect.Array:
+		return e.array(v)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		key := v.UnsafePointer()
+		if err := e.enter(v, key); err != nil {
+			return nil, err
+		}
+		i, err := e.value(v.Elem(), quoted)
+		e.leave(key)
+		return i, err
+	default:
+		return nil, e.errorf("unsupported type %s", t)
+	}
+}
+
+// enter increments the nesting level of pointers and
+// returns an error if the value identified by key was
+// already visited by one of the parents of the value.
+func (e *Encoder) enter(v reflect.Value, key any) error {
+	e.ptrLevel++
+	if e.ptrLevel <= startDetectingCyclesAfter {
+		return nil
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[any]struct{})
+	}
+	if _, ok := e.ptrSeen[key]; ok {
+		e.ptrLevel--
+		return e.errorf("encountered a cycle via %s", v.Type())
+	}
+	e.ptrSeen[key] = struct{}{}
+	return nil
+}
+
+// leave decrements the nesting level of pointers, and
+// forgets the value identified by key, which may be
+// visited again by a value that is not one of its
+// children without being a cycle.
+func (e *Encoder) leave(key any) {
+	if e.ptrLevel > startDetectingCyclesAfter {
+		delete(e.ptrSeen, key)
+	}
+	e.ptrLevel--
+}
+
+func (e *Encoder) marshaler(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	m, ok := v.Interface().(json.Marshaler)
+	if !ok {
+		return nil, nil
+	}
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, e.errorf("error calling MarshalJSON for type %s: %s", v.Type(), err)
+	}
+	var i interface{}
+	if err := json.Unmarshal(b, &i); err != nil {
+		return nil, e.errorf("error calling MarshalJSON for type %s: %s", v.Type(), err)
+	}
+	return i, nil
+}
+
+func (e *Encoder) textMarshaler(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		return nil, nil
+	}
+	b, err := m.MarshalText()
+	if err != nil {
+		return nil, e.errorf("error calling MarshalText for type %s: %s", v.Type(), err)
+	}
+	return validString(string(b)), nil
+}
+
+func (e *Encoder) float(f float64, bits int, quoted bool) (interface{}, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, e.errorf("unsupported value %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	if quoted {
+		return formatFloat(f, bits), nil
+	}
+	if bits == 32 {
+		// The shortest representation of a float32
+		// is not the one of the same float64 value.
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', -1, 32), 64)
+	}
+	return f, nil
+}
+
+func (e *Encoder) number(n json.Number, quoted bool) (interface{}, error) {
+	if n == "" {
+		n = "0"
+	}
+	if quoted {
+		return string(n), nil
+	}
+	var i interface{}
+	if err := json.Unmarshal([]byte(n), &i); err != nil {
+		return nil, e.errorf("invalid number literal %q", string(n))
+	}
+	if _, ok := i.(float64); !ok {
+		return nil, e.errorf("invalid number literal %q", string(n))
+	}
+	return i, nil
+}
+
+func (e *Encoder) array(v reflect.Value) (interface{}, error) {
+	arr := make([]interface{}, v.Len())
+
+	ptr := e.ptr
+	for i := range arr {
+		e.ptr = ptr
+		e.ptr.AppendIndex(i)
+		ev, err := e.value(v.Index(i), false)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = ev
+	}
+	e.ptr = ptr
+
+	return arr, nil
+}
+
+func (e *Encoder) object(v reflect.Value) (interface{}, error) {
+	fields := cachedFields(v.Type())
+	obj := make(map[string]interface{}, len(fields))
+
+	ptr := e.ptr
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			// Embedded through a nil pointer.
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if f.omitZero && isZeroValue(fv) {
+			continue
+		}
+		e.ptr = ptr
+		e.ptr.AppendKey(f.name)
+		val, err := e.value(fv, f.quoted)
+		if err != nil {
+			return nil, err
+		}
+		obj[f.name] = val
+	}
+	e.ptr = ptr
+
+	return obj, nil
+}
+
+func (e *Encoder) mapObject(v reflect.Value) (interface{}, error) {
+	t := v.Type()
+	switch kt := t.Key(); kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !kt.Implements(textMarshalerType) {
+			return nil, e.errorf("unsupported type %s", t)
+		}
+	}
+	if v.IsNil() {
+		return nil, nil
+	}
+	key := v.UnsafePointer()
+	if err := e.enter(v, key); err != nil {
+		return nil, err
+	}
+	defer e.leave(key)
+
+	obj := make(map[string]interface{}, v.Len())
+
+	ptr := e.ptr
+	it := v.MapRange()
+	for it.Next() {
+		key, err := e.mapKey(it.Key())
+		if err != nil {
+			return nil, err
+		}
+		e.ptr = ptr
+		e.ptr.AppendKey(key)
+		val, err := e.value(it.Value(), false)
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = val
+	}
+	e.ptr = ptr
+
+	return obj, nil
+}
+
+func (e *Encoder) mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return validString(k.String()), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		if err != nil {
+			return "", e.errorf("error calling MarshalText for type %s: %s", k.Type(), err)
+		}
+		return validString(string(b)), nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	default:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+}
+
+// fieldByIndex returns the nested field of v located at
+// index. It returns false if the field is embedded through
+// a nil pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// formatFloat formats f like the encoding/json package.
+func formatFloat(f float64, bits int) string {
+	abs := math.Abs(f)
+	fmtByte := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmtByte = 'e'
+		}
+	}
+	s := strconv.FormatFloat(f, fmtByte, -1, bits)
+	if fmtByte == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-3] == '-' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s
+}
+
+// validString replaces the invalid UTF-8 bytes of s with
+// the Unicode replacement character, as they would be when
+// encoded as a JSON string.
+func validString(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			sb.WriteRune(utf8.RuneError)
+		} else {
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+func isByteSlice(t reflect.Type) bool {
+	if t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	p := reflect.PointerTo(t.Elem())
+	return !p.Implements(marshalerType) && !p.Implements(textMarshalerType)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+func isZeroValue(v reflect.Value) bool {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return v.IsNil() || v.Interface().(isZeroer).IsZero()
+	case t.Implements(isZeroerType):
+		if t.Kind() == reflect.Interface && v.IsNil() {
+			return true
+		}
+		return v.Interface().(isZeroer).IsZero()
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		if !v.CanAddr() {
+			// Temporarily box the value to call
+			// the method with a pointer receiver.
+			v2 := reflect.New(t).Elem()
+			v2.Set(v)
+			v = v2
+		}
+		return v.Addr().Interface().(isZeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
+// A field represents a single field of a struct
+// that is encoded as a member of a JSON object.
+type field struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	tagged    bool
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields returns the fields of the struct type t that
+// are encoded, following the rules of the encoding/json
+// package for embedded structs: the fields are promoted
+// from the embedded structs, and a field with a given name
+// is hidden by a field with the same name at a lower depth,
+// or with a tag at the same depth. Conflicting fields are
+// omitted.
+func typeFields(t reflect.Type) []field {
+	// Fields of the embedded structs to explore
+	// at the current and next depth.
+	var current []field
+	next := []field{{typ: t}}
+
+	// Count of the types at the current and next depth,
+	// used to detect the duplicates of embedded structs.
+	var count, nextCount map[reflect.Type]int
+
+	visited := make(map[reflect.Type]bool)
+
+	var fields []field
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, make(map[reflect.Type]int)
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Pointer {
+						t = t.Elem()
+					}
+					if !sf.IsExported() && t.Kind() != reflect.Struct {
+						continue
+					}
+					// Fields of embedded structs of an unexported
+					// type are promoted, if they are exported.
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				quoted := false
+				if hasTagOption(opts, "string") {
+					switch ft.Kind() {
+					case reflect.Bool,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64,
+						reflect.String:
+						quoted = true
+					}
+				}
+				// Record the field, unless it is an untagged
+				// embedded struct, whose fields are explored
+				// at the next depth.
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					fields = append(fields, field{
+						name:      name,
+						index:     index,
+						typ:       ft,
+						tagged:    tagged,
+						omitEmpty: hasTagOption(opts, "omitempty"),
+						omitZero:  hasTagOption(opts, "omitzero"),
+						quoted:    quoted,
+					})
+					if count[f.typ] > 1 {
+						// The struct is embedded several times at
+						// the same depth, add a duplicate so that
+						// the field is annihilated below.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+	// Sort the fields by name, breaking ties with depth,
+	// then with the presence of a tag, then with index
+	// sequence, so that the dominant field comes first.
+	slices.SortFunc(fields, func(a, b field) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		if c := len(a.index) - len(b.index); c != 0 {
+			return c
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return slices.Compare(a.index, b.index)
+	})
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		// The dominant field is the first of the group, unless
+		// the second one has the same depth and tag status.
+		if j-i == 1 || len(fields[i].index) != len(fields[i+1].index) || fields[i].tagged != fields[i+1].tagged {
+			out = append(out, fields[i])
+		}
+		i = j
+	}
+	fields = out
+
+	slices.SortFunc(fields, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return fields
+}
+
+func hasTagOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
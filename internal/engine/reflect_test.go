@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type (
+	textKey      int
+	ptrMarshaler struct{ V int }
+	zeroer       struct{ V int }
+	embeddedA    struct {
+		A    string
+		Dup  string
+		Name string `json:"name"`
+	}
+	embeddedB struct {
+		B   string
+		Dup string
+	}
+	embeddedTagged struct {
+		C string
+	}
+	unexportedEmbedded struct {
+		D string
+		e string
+	}
+	nested struct {
+		embeddedA
+		*embeddedB
+		embeddedTagged `json:"tagged"`
+		unexportedEmbedded
+		Name string
+	}
+	tagged struct {
+		Renamed   string            `json:"renamed"`
+		Skipped   string            `json:"-"`
+		Dash      string            `json:"-,"`
+		Empty     string            `json:",omitempty"`
+		EmptyPtr  *int              `json:",omitempty"`
+		EmptyMap  map[string]int    `json:",omitempty"`
+		Zero      time.Time         `json:",omitzero"`
+		ZeroCust  zeroer            `json:",omitzero"`
+		ZeroStr   struct{ A int }   `json:",omitzero"`
+		Int       int               `json:",string"`
+		Uint      *uint8            `json:",string"`
+		Bool      bool              `json:",string"`
+		Float     float64           `json:",string"`
+		SmallF    float32           `json:",string"`
+		Str       string            `json:",string"`
+		unexport  string            //nolint:unused
+		Any       interface{}       `json:"any"`
+		Raw       json.RawMessage   `json:"raw"`
+		Number    json.Number       `json:"number"`
+		Bytes     []byte            `json:"bytes"`
+		Array     [2]byte           `json:"array"`
+		NilSlice  []string          `json:"nilSlice"`
+		TextKeys  map[textKey]int   `json:"textKeys"`
+		IntKeys   map[int8]string   `json:"intKeys"`
+		IP        net.IP            `json:"ip"`
+		Time      time.Time         `json:"time"`
+		PtrMarsh  ptrMarshaler      `json:"ptrMarsh"`
+		Float32   float32           `json:"float32"`
+		BadUTF8   string            `json:"badUTF8"`
+		StrMap    map[string]string `json:"strMap"`
+		Interface json.Marshaler    `json:"interface"`
+	}
+)
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("k", int(k))), nil
+}
+
+func (m *ptrMarshaler) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{"value": m.V})
+}
+
+func (z zeroer) IsZero() bool { return z.V < 0 }
+
+func Test_encoder(t *testing.T) {
+	u := uint8(42)
+	for _, tc := range []struct {
+		name string
+		val  any
+	}{
+		{"nil", nil},
+		{"scalars", []any{true, 1, int8(-2), uint64(3), 4.5, float32(0.1), "s", uintptr(6)}},
+		{"nil pointer", (*int)(nil)},
+		{"pointer", &u},
+		{"map of maps", map[string]map[string][]int{"a": {"b": {1, 2}}}},
+		{"nested", nested{
+			embeddedA:          embeddedA{A: "a", Dup: "dup", Name: "tag"},
+			embeddedB:          &embeddedB{B: "b", Dup: "dup"},
+			embeddedTagged:     embeddedTagged{C: "c"},
+			unexportedEmbedded: unexportedEmbedded{D: "d", e: "e"},
+			Name:               "name",
+		}},
+		{"nested nil embedded pointer", nested{}},
+		{"tagged", tagged{
+			Renamed:  "r",
+			Skipped:  "s",
+			Dash:     "d",
+			ZeroCust: zeroer{V: -1},
+			ZeroStr:  struct{ A int }{},
+			Int:      -1,
+			Uint:     &u,
+			Bool:     true,
+			Float:    1e-7,
+			SmallF:   1e21,
+			Str:      "<a&b>",
+			Any:      []interface{}{"x", 1},
+			Raw:      json.RawMessage(`{"z":[1,2]}`),
+			Number:   "12.5e3",
+			Bytes:    []byte("hello"),
+			Array:    [2]byte{1, 2},
+			TextKeys: map[textKey]int{1: 1, 2: 2},
+			IntKeys:  map[int8]string{-1: "a"},
+			IP:       net.IPv4(127, 0, 0, 1),
+			Time:     time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+			PtrMarsh: ptrMarshaler{V: 1},
+			Float32:  3.14,
+			BadUTF8:  "a\xffb\xfe\xfd",
+			StrMap:   map[string]string{"k": "v"},
+		}},
+		{"tagged zero", tagged{ZeroCust: zeroer{V: 1}}},
+		{"addressable pointer marshaler", &tagged{PtrMarsh: ptrMarshaler{V: 2}}},
+		{"slice of addressable elements", []ptrMarshaler{{V: 3}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var e Encoder
+			got, err := e.Encode(tc.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(tc.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want any
+			if err := json.Unmarshal(b, &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				gb, _ := json.Marshal(got)
+				wb, _ := json.Marshal(want)
+				t.Errorf("got %s, want %s", gb, wb)
+			}
+		})
+	}
+}
+
+func Test_encoder_sharedPointers(t *testing.T) {
+	type node struct {
+		Next *node `json:"next,omitempty"`
+		A    *int  `json:"a,omitempty"`
+		B    *int  `json:"b,omitempty"`
+	}
+	// The values referenced by the pointers beyond the
+	// nesting level at which cycles are detected are
+	// shared by siblings, which is not a cycle.
+	n := new(int)
+	root := &node{}
+	cur := root
+	for i := 0; i < startDetectingCyclesAfter+10; i++ {
+		cur.Next = &node{}
+		cur = cur.Next
+	}
+	cur.A, cur.B = n, n
+	cur.Next = &node{A: n}
+
+	var e Encoder
+	got, err := e.Encode(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want any
+	if err := json.Unmarshal(b, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("got unexpected encoded value")
+	}
+}
+
+func Test_encoder_errors(t *testing.T) {
+	type cycle struct {
+		Next *cycle `json:"next"`
+	}
+	c := &cycle{}
+	c.Next = c
+
+	for _, tc := range []struct {
+		name string
+		val  any
+		ptr  string
+	}{
+		{"nan", map[string]any{"a": []any{1, math.NaN()}}, "/a/1"},
+		{"channel", struct{ C chan int }{}, "/C"},
+		{"func", []any{func() {}}, "/0"},
+		{"complex", map[string]complex64{"a~b": 1}, "/a~0b"},
+		{"map key", map[[2]int]int{}, ""},
+		{"cycle", c, "/next/next"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var e Encoder
+			_, err := e.Encode(tc.val)
+			if err == nil {
+				t.Fatal("expected non-nil error")
+			}
+			if !strings.Contains(err.Error(), "at \""+tc.ptr) {
+				t.Errorf("error %q does not contain pointer %q", err, tc.ptr)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// ValueType represents the type of JSON value.
+// It follows the types of values stored by json.Unmarshal
+// in interface values.
+type ValueType uint
+
+const (
+	Invalid ValueType = iota
+	Null
+	String
+	Boolean
+	NumberFloat
+	NumberString
+	Array
+	Object
+)
+
+// TypeOf returns the JSON type of the value
+// held by the interface using a type switch statement.
+func TypeOf(i interface{}) ValueType {
+	switch i.(type) {
+	case nil:
+		return Null
+	case string:
+		return String
+	case bool:
+		return Boolean
+	case float64:
+		return NumberFloat
+	case json.Number:
+		return NumberString
+	case []interface{}:
+		return Array
+	case map[string]interface{}:
+		return Object
+	default:
+		return Invalid
+	}
+}
+
+var typeNames = []string{
+	Invalid:      "Invalid",
+	Boolean:      "Boolean",
+	NumberFloat:  "Number",
+	NumberString: "json.Number",
+	String:       "String",
+	Null:         "Null",
+	Object:       "Object",
+	Array:        "Array",
+}
+
+// String implements fmt.Stringer for ValueType.
+func (t ValueType) String() string {
+	if uint(t) < uint(len(typeNames)) {
+		return typeNames[t]
+	}
+	return "type" + strconv.Itoa(int(t))
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func reflectKind(i interface{}) reflect.Kind {
+	return reflect.TypeOf(i).Kind()
+}
+
+func BenchmarkGetType(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping benchmark in short mode")
+	}
+	m := map[string]interface{}{}
+
+	b.Run("reflect", func(b *testing.B) {
+		for b.Loop() {
+			_ = reflectKind(m)
+		}
+	})
+	b.Run("typeSwitch", func(b *testing.B) {
+		for b.Loop() {
+			_ = TypeOf(m)
+		}
+	})
+}
+
+func TestTypeOf(t *testing.T) {
+	for _, tc := range []struct {
+		val   any
+		valid bool
+		kind  ValueType
+	}{
+		{
+			"foo",
+			true,
+			String,
+		},
+		{
+			false,
+			true,
+			Boolean,
+		},
+		{
+			float32(3.14),
+			false,
+			Invalid,
+		},
+		{
+			nil,
+			true,
+			Null,
+		},
+		{
+			&struct{}{},
+			false,
+			Invalid,
+		},
+		{
+			3.14,
+			true,
+			NumberFloat,
+		},
+		{
+			json.Number("3.14"),
+			true,
+			NumberString,
+		},
+		{
+			func() {},
+			false,
+			Invalid,
+		},
+		{
+			[]interface{}{},
+			true,
+			Array,
+		},
+		{
+			map[string]interface{}{},
+			true,
+			Object,
+		},
+	} {
+		k := TypeOf(tc.val)
+		if k != tc.kind {
+			t.Errorf("got %s, want %s", k, tc.kind)
+		}
+	}
+}
+
+func TestValueType_String(t *testing.T) {
+	for typ := 0; typ < len(typeNames); typ++ {
+		s := ValueType(typ).String()
+		if s != typeNames[typ] {
+			t.Errorf("got %q, want %q", s, typeNames[typ])
+		}
+	}
+	unknownType := ValueType(9000)
+	s := unknownType.String()
+
+	const want = "type9000"
+	if s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,208 @@
+package jsondiff
+
+import (
+	"fmt"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// InvertWith returns a patch that undo the modifications
+// represented by this patch, when it is applied to the source
+// document src. Unlike Invert, the patch does not need test
+// operations: it is applied to a copy of the document, which
+// gives the values that its operations replace or remove, and
+// the actual array indices of the values it appends. Any valid
+// patch can be inverted, including copy and move operations.
+//
+// Like with Invert, the add, copy and replace operations are
+// inverted into a test operation, which checks the value they
+// set, followed by the operation that restores the original
+// value. The test operations of the patch are preserved. The
+// source document is never modified. If the patch cannot be
+// applied to it, an error of type *PatchError is returned.
+func (p Patch) InvertWith(src any) (Patch, error) {
+	var a engine.Applier
+
+	doc, err := a.Normalize(src)
+	if err != nil {
+		return nil, err
+	}
+	doc = engine.DeepCopy(doc)
+
+	inverses := make([][]Operation, len(p))
+	size := 0
+	for i, op := range p {
+		ops, err := invertOp(&a, &doc, op)
+		if err != nil {
+			return nil, patchError(err, i)
+		}
+		inverses[i] = ops
+		size += len(ops)
+	}
+	res := make(Patch, 0, size)
+	for i := len(inverses) - 1; i >= 0; i-- {
+		res = append(res, inverses[i]...)
+	}
+	return res, nil
+}
+
+// invertOp applies the operation to the document referenced
+// by doc, and returns the operations that undo it.
+func invertOp(a *engine.Applier, doc *any, op Operation) ([]Operation, *engine.Error) {
+	ptr, err := engine.ParsePath(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Type {
+	case OperationMove:
+		return invertMove(a, doc, op, ptr)
+	case OperationAdd, OperationCopy, OperationReplace, OperationRemove, OperationTest:
+	default:
+		return nil, a.ApplyOp(doc, op.operation())
+	}
+	old, found := memberValue(*doc, ptr)
+	if op.Type == OperationRemove || op.Type == OperationReplace {
+		// The value must exist, and may be an array element.
+		v, err := engine.GetValue(*doc, ptr)
+		if err != nil {
+			return nil, err
+		}
+		old, found = engine.DeepCopy(v), true
+	}
+	if err := a.ApplyOp(doc, op.operation()); err != nil {
+		return nil, err
+	}
+	switch op.Type {
+	case OperationTest:
+		return []Operation{op}, nil
+	case OperationRemove:
+		return []Operation{{
+			Type:  OperationAdd,
+			Path:  op.Path,
+			Value: old,
+		}}, nil
+	}
+	// The value set by the operation.
+	ptr = appended(*doc, ptr)
+	v, _ := engine.GetValue(*doc, ptr)
+	v = engine.DeepCopy(v)
+
+	ops := []Operation{{
+		Type:  OperationTest,
+		Path:  ptr.Str,
+		Value: v,
+	}}
+	if !found {
+		return append(ops, Operation{
+			Type:     OperationRemove,
+			Path:     ptr.Str,
+			OldValue: v,
+		}), nil
+	}
+	return append(ops, Operation{
+		Type:     OperationReplace,
+		Path:     ptr.Str,
+		Value:    old,
+		OldValue: v,
+	}), nil
+}
+
+// invertMove applies the move operation to the document
+// referenced by doc, and returns the operations that undo it.
+func invertMove(a *engine.Applier, doc *any, op Operation, ptr engine.ParsedPointer) ([]Operation, *engine.Error) {
+	from, err := engine.ParsePath(op.From)
+	if err != nil {
+		return nil, err
+	}
+	if isPrefix(from, ptr) && len(from.Tokens) < len(ptr.Tokens) {
+		return nil, &engine.Error{
+			Err:     fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidOperation),
+			Pointer: op.From,
+		}
+	}
+	v, err := engine.RemoveValue(doc, from)
+	if err != nil {
+		return nil, err
+	}
+	// The value overwritten at the path, if any, is
+	// looked up once the value has been removed.
+	old, found := memberValue(*doc, ptr)
+
+	if err := engine.AddValue(doc, ptr, v); err != nil {
+		engine.AddValue(doc, from, v)
+		return nil, err
+	}
+	ptr = appended(*doc, ptr)
+
+	if op.From == ptr.Str {
+		return nil, nil
+	}
+	v = engine.DeepCopy(v)
+
+	switch {
+	case found:
+		// Restore the overwritten value before
+		// the moved one, since the pointers of
+		// the moved value refer to the document
+		// in which it is removed.
+		return []Operation{
+			{Type: OperationTest, Path: ptr.Str, Value: v},
+			{Type: OperationReplace, Path: ptr.Str, Value: old, OldValue: v},
+			{Type: OperationAdd, Path: op.From, Value: v},
+		}, nil
+	case isPrefix(ptr, from):
+		// The value cannot be moved into one of
+		// the children of its location.
+		return []Operation{
+			{Type: OperationTest, Path: ptr.Str, Value: v},
+			{Type: OperationRemove, Path: ptr.Str, OldValue: v},
+			{Type: OperationAdd, Path: op.From, Value: v},
+		}, nil
+	}
+	return []Operation{{
+		Type:  OperationMove,
+		From:  ptr.Str,
+		Path:  op.From,
+		Value: op.Value,
+	}}, nil
+}
+
+// memberValue returns the value located at the path, if
+// it exists and is not an array element, which an add
+// operation would insert.
+func memberValue(doc any, p engine.ParsedPointer) (any, bool) {
+	if len(p.Tokens) == 0 {
+		return engine.DeepCopy(doc), true
+	}
+	parent, err := engine.GetValue(doc, ancestor(p, len(p.Tokens)-1))
+	if err != nil {
+		return nil, false
+	}
+	m, ok := parent.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok := m[p.Tokens[len(p.Tokens)-1]]
+	if !ok {
+		return nil, false
+	}
+	return engine.DeepCopy(v), true
+}
+
+// appended returns the pointer p to a value added to the
+// document, whose "-" token, if it refers to the end of
+// an array, is replaced by the index of the value.
+func appended(doc any, p engine.ParsedPointer) engine.ParsedPointer {
+	if !isAppend(p) {
+		return p
+	}
+	n := len(p.Tokens) - 1
+	parent, err := engine.GetValue(doc, ancestor(p, n))
+	if err != nil {
+		return p
+	}
+	if arr, ok := parent.([]any); ok {
+		return withIndex(p, n, len(arr)-1)
+	}
+	return p
+}
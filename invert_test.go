@@ -0,0 +1,155 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+func TestPatch_InvertWith(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{
+			"add and remove",
+			`{"a":1,"b":[1,2]}`,
+			`[{"op":"add","path":"/c","value":3},{"op":"remove","path":"/b/0"},{"op":"add","path":"/b/-","value":4}]`,
+			`[
+				{"op":"test","path":"/b/1","value":4},
+				{"op":"remove","path":"/b/1"},
+				{"op":"add","path":"/b/0","value":1},
+				{"op":"test","path":"/c","value":3},
+				{"op":"remove","path":"/c"}
+			]`,
+		},
+		{
+			"replace",
+			`{"a":{"b":1}}`,
+			`[{"op":"replace","path":"/a/b","value":2},{"op":"add","path":"/a","value":3}]`,
+			`[
+				{"op":"test","path":"/a","value":3},
+				{"op":"replace","path":"/a","value":{"b":2}},
+				{"op":"test","path":"/a/b","value":2},
+				{"op":"replace","path":"/a/b","value":1}
+			]`,
+		},
+		{
+			"copy",
+			`{"a":[1,2],"b":{"c":1}}`,
+			`[{"op":"copy","from":"/a/0","path":"/a/0"},{"op":"copy","from":"/a","path":"/b/c"}]`,
+			`[
+				{"op":"test","path":"/b/c","value":[1,1,2]},
+				{"op":"replace","path":"/b/c","value":1},
+				{"op":"test","path":"/a/0","value":1},
+				{"op":"remove","path":"/a/0"}
+			]`,
+		},
+		{
+			"array moves",
+			`{"a":[1,2,3],"b":[]}`,
+			`[{"op":"move","from":"/a/0","path":"/a/-"},{"op":"move","from":"/a/1","path":"/b/0"}]`,
+			`[
+				{"op":"move","from":"/b/0","path":"/a/1"},
+				{"op":"move","from":"/a/2","path":"/a/0"}
+			]`,
+		},
+		{
+			"move overwriting a member",
+			`{"a":{"b":1,"c":2}}`,
+			`[{"op":"move","from":"/a/b","path":"/a/c"},{"op":"move","from":"/a/c","path":"/a"}]`,
+			`[
+				{"op":"test","path":"/a","value":1},
+				{"op":"replace","path":"/a","value":{}},
+				{"op":"add","path":"/a/c","value":1},
+				{"op":"test","path":"/a/c","value":1},
+				{"op":"replace","path":"/a/c","value":2},
+				{"op":"add","path":"/a/b","value":1}
+			]`,
+		},
+		{
+			"move to a parent array",
+			`{"a":[{"b":1}]}`,
+			`[{"op":"move","from":"/a/0/b","path":"/a/0"},{"op":"move","from":"/a/1","path":"/a/1"}]`,
+			`[
+				{"op":"test","path":"/a/0","value":1},
+				{"op":"remove","path":"/a/0"},
+				{"op":"add","path":"/a/0/b","value":1}
+			]`,
+		},
+		{
+			"test",
+			`{"a":1}`,
+			`[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/a"}]`,
+			`[{"op":"add","path":"/a","value":1},{"op":"test","path":"/a","value":1}]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				doc         any
+				patch, want Patch
+			)
+			if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []struct {
+				s string
+				p *Patch
+			}{{tc.patch, &patch}, {tc.want, &want}} {
+				if err := json.Unmarshal([]byte(v.s), v.p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := patch.InvertWith(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("got patch:\n%s\nwant:\n%s", got.String(), want.String())
+			}
+			tgt, err := applyPatch(doc, patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := applyPatch(tgt, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := mustMarshal(t, v); !jsonStringEqual(t, s, tc.doc) {
+				t.Errorf("got %s, want %s", s, tc.doc)
+			}
+			// The source document must not be modified.
+			if s := mustMarshal(t, doc); !jsonStringEqual(t, s, tc.doc) {
+				t.Errorf("source document was modified: %s", s)
+			}
+		})
+	}
+}
+
+func TestPatch_InvertWith_errors(t *testing.T) {
+	doc := map[string]any{"a": 1}
+
+	_, err := Patch{
+		{Type: OperationReplace, Path: "/a", Value: 2},
+		{Type: OperationRemove, Path: "/b"},
+	}.InvertWith(doc)
+
+	var perr *PatchError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PatchError", err)
+	}
+	if !errors.Is(err, engine.ErrPathNotFound) || perr.Index != 1 {
+		t.Errorf("got error %v, want %v for operation #1", err, engine.ErrPathNotFound)
+	}
+	_, err = Patch{{Type: OperationMove, From: "/a", Path: "/a/b"}}.InvertWith(doc)
+	if !errors.Is(err, ErrInvalidOperation) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidOperation)
+	}
+	if _, err := (Patch{}).InvertWith(make(chan int)); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
@@ -0,0 +1,99 @@
+// Package jsonpatch applies JSON Patch (RFC 6902) documents,
+// such as the patches generated by the jsondiff package, to
+// decoded JSON documents.
+package jsonpatch
+
+import (
+	"fmt"
+
+	"github.com/wI2L/jsondiff"
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+var (
+	// ErrTestFailed is returned when the value located at
+	// the path of a test operation is not equal to the value
+	// of the operation.
+	ErrTestFailed = engine.ErrTestFailed
+
+	// ErrPathNotFound is returned when a location referenced
+	// by an operation does not exist in the document.
+	ErrPathNotFound = engine.ErrPathNotFound
+
+	// ErrTypeMismatch is returned when a location referenced
+	// by an operation cannot be resolved because the type of
+	// a value does not match the pointer, such as a scalar
+	// value with children, or an array with a member name.
+	ErrTypeMismatch = engine.ErrTypeMismatch
+)
+
+// An ApplyError is returned when an operation of a patch
+// cannot be applied to a document.
+type ApplyError struct {
+	// Err is the cause of the failure. It wraps one of
+	// ErrTestFailed, ErrPathNotFound, ErrTypeMismatch
+	// or jsondiff.ErrInvalidOperation.
+	Err error
+
+	// Op is the operation that failed, and Index its
+	// position in the patch.
+	Op    jsondiff.Operation
+	Index int
+
+	// Pointer is the location of the document at which
+	// the operation failed, which may be a parent of the
+	// path or the origin of the operation.
+	Pointer string
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("jsonpatch: operation #%d (%s %q): %s at %q", e.Index, e.Op.Type, e.Op.Path, e.Err, e.Pointer)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies the patch to the document doc, according to
+// the semantics of RFC 6902, and returns the patched document.
+//
+// The document may consist of the values produced by the
+// json.Unmarshal function, or of any value supported by the
+// jsondiff.CompareWithoutMarshal function, and so do the values
+// of the operations. The patch is applied atomically to a copy
+// of the document, which is never modified: if an operation
+// fails, an error of type *ApplyError is returned, and no
+// document.
+//
+// The options relax the semantics of RFC 6902, to apply
+// patches to documents that differ from the ones they
+// were generated from.
+func Apply(doc any, patch jsondiff.Patch, opts ...Option) (any, error) {
+	v, f, err := engine.NewApplier(options(opts)).Apply(doc, operations(patch))
+	if err != nil {
+		return nil, err
+	}
+	if f != nil {
+		return nil, newError(f, patch)
+	}
+	return v, nil
+}
+
+// operations returns the operations of the
+// patch, in the form used by the engine.
+func operations(patch jsondiff.Patch) []engine.Operation {
+	ops := make([]engine.Operation, len(patch))
+	for i, op := range patch {
+		ops[i] = engine.Operation{Type: op.Type, From: op.From, Path: op.Path, Value: op.Value}
+	}
+	return ops
+}
+
+func newError(f *engine.Failure, patch jsondiff.Patch) *ApplyError {
+	return &ApplyError{
+		Err:     f.Err,
+		Op:      patch[f.Index],
+		Index:   f.Index,
+		Pointer: f.Pointer,
+	}
+}
@@ -0,0 +1,439 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wI2L/jsondiff"
+)
+
+func TestApply(t *testing.T) {
+	// Examples of RFC 6902 Appendix A.
+	// https://datatracker.ietf.org/doc/html/rfc6902#appendix-A
+	for _, tc := range []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+		ptr   string
+	}{
+		{
+			"adding an object member",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"baz":"qux","foo":"bar"}`,
+			nil, "",
+		},
+		{
+			"adding an array element",
+			`{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`,
+			nil, "",
+		},
+		{
+			"removing an object member",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`,
+			nil, "",
+		},
+		{
+			"removing an array element",
+			`{"foo":["bar","qux","baz"]}`,
+			`[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`,
+			nil, "",
+		},
+		{
+			"replacing a value",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`,
+			nil, "",
+		},
+		{
+			"moving a value",
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+			nil, "",
+		},
+		{
+			"moving an array element",
+			`{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`,
+			nil, "",
+		},
+		{
+			"testing a value: success",
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+			nil, "",
+		},
+		{
+			"testing a value: error",
+			`{"baz":"qux"}`,
+			`[{"op":"test","path":"/baz","value":"bar"}]`,
+			``,
+			ErrTestFailed, "/baz",
+		},
+		{
+			"adding a nested member object",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`,
+			nil, "",
+		},
+		{
+			"adding to a nonexistent target",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			``,
+			ErrPathNotFound, "/baz",
+		},
+		{
+			"escape ordering",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":10}]`,
+			`{"/":9,"~1":10}`,
+			nil, "",
+		},
+		{
+			"comparing strings and numbers",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":"10"}]`,
+			``,
+			ErrTestFailed, "/~01",
+		},
+		{
+			"adding an array value",
+			`{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`,
+			nil, "",
+		},
+		// Custom tests.
+		{
+			"replace root",
+			`{"foo":"bar"}`,
+			`[{"op":"replace","path":"","value":[1]}]`,
+			`[1]`,
+			nil, "",
+		},
+		{
+			"test deep equal",
+			`{"a":{"b":[1,{"c":null}],"d":true}}`,
+			`[{"op":"test","path":"/a","value":{"d":true,"b":[1.0,{"c":null}]}}]`,
+			`{"a":{"b":[1,{"c":null}],"d":true}}`,
+			nil, "",
+		},
+		{
+			"test deep unequal",
+			`{"a":{"b":[1,{"c":null}]}}`,
+			`[{"op":"test","path":"/a","value":{"b":[1,{"c":false}]}}]`,
+			``,
+			ErrTestFailed, "/a",
+		},
+		{
+			"copy",
+			`{"a":{"b":[1]}}`,
+			`[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			`{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+			nil, "",
+		},
+		{
+			"insert at end of array",
+			`[1,2]`,
+			`[{"op":"add","path":"/2","value":3}]`,
+			`[1,2,3]`,
+			nil, "",
+		},
+		{
+			"insert out of bounds",
+			`[1,2]`,
+			`[{"op":"add","path":"/3","value":3}]`,
+			``,
+			ErrPathNotFound, "/3",
+		},
+		{
+			"invalid index",
+			`{"a":[1,2]}`,
+			`[{"op":"replace","path":"/a/01","value":3}]`,
+			``,
+			ErrTypeMismatch, "/a/01",
+		},
+		{
+			"remove dash index",
+			`[1,2]`,
+			`[{"op":"remove","path":"/-"}]`,
+			``,
+			ErrPathNotFound, "/-",
+		},
+		{
+			"replace missing member",
+			`{"a":1}`,
+			`[{"op":"replace","path":"/b","value":3}]`,
+			``,
+			ErrPathNotFound, "/b",
+		},
+		{
+			"traverse scalar",
+			`{"a":"foo"}`,
+			`[{"op":"add","path":"/a/b/c","value":3}]`,
+			``,
+			ErrTypeMismatch, "/a/b",
+		},
+		{
+			"move into a child",
+			`{"a":{"b":{}}}`,
+			`[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			``,
+			jsondiff.ErrInvalidOperation, "/a",
+		},
+		{
+			"unknown operation",
+			`{}`,
+			`[{"op":"merge","path":"/a"}]`,
+			``,
+			jsondiff.ErrInvalidOperation, "/a",
+		},
+		{
+			"invalid pointer",
+			`{}`,
+			`[{"op":"add","path":"a","value":1}]`,
+			``,
+			jsondiff.ErrInvalidOperation, "a",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				doc   any
+				patch jsondiff.Patch
+			)
+			if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(doc, patch)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("got error %v, want %v", err, tc.err)
+				}
+				var aerr *ApplyError
+				if !errors.As(err, &aerr) {
+					t.Fatalf("expected error of type %T", aerr)
+				}
+				if aerr.Pointer != tc.ptr {
+					t.Errorf("got error pointer %q, want %q", aerr.Pointer, tc.ptr)
+				}
+				if got != nil {
+					t.Errorf("expected nil document")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want any
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApply_atomic(t *testing.T) {
+	doc := map[string]any{
+		"a": []any{1.0, 2.0},
+		"b": map[string]any{"c": "d"},
+	}
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationAdd, Path: "/a/0", Value: 0},
+		{Type: jsondiff.OperationRemove, Path: "/b/c"},
+		{Type: jsondiff.OperationTest, Path: "/a/0", Value: 1},
+	}
+	got, err := Apply(doc, patch)
+	if err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	var aerr *ApplyError
+	if !errors.As(err, &aerr) || aerr.Index != 2 || aerr.Op.Type != jsondiff.OperationTest {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("expected nil document, got %v", got)
+	}
+	want := map[string]any{
+		"a": []any{1.0, 2.0},
+		"b": map[string]any{"c": "d"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("document was modified: %v", doc)
+	}
+	// Without the failing operation, the document is
+	// patched, but the original is still unmodified.
+	got, err = Apply(doc, patch[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("document was modified: %v", doc)
+	}
+	want = map[string]any{
+		"a": []any{0.0, 1.0, 2.0},
+		"b": map[string]any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestApply_numbers(t *testing.T) {
+	doc := map[string]any{"a": json.Number("1.50"), "b": 2}
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationTest, Path: "/a", Value: 1.5},
+		{Type: jsondiff.OperationTest, Path: "/b", Value: json.Number("2e0")},
+	}
+	if _, err := Apply(doc, patch); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApply_lenient(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		doc   string
+		patch jsondiff.Patch
+		opts  []Option
+		want  string
+		err   error
+	}{
+		{
+			"create parents",
+			`{"a":{}}`,
+			jsondiff.Patch{{Type: jsondiff.OperationAdd, Path: "/a/b/c/d", Value: 1}},
+			[]Option{CreateParents()},
+			`{"a":{"b":{"c":{"d":1}}}}`,
+			nil,
+		},
+		{
+			"create parents in array element",
+			`{"a":[{}]}`,
+			jsondiff.Patch{{Type: jsondiff.OperationAdd, Path: "/a/0/b/c", Value: 1}},
+			[]Option{CreateParents()},
+			`{"a":[{"b":{"c":1}}]}`,
+			nil,
+		},
+		{
+			"create parents without array elements",
+			`{"a":[]}`,
+			jsondiff.Patch{{Type: jsondiff.OperationAdd, Path: "/a/0/b", Value: 1}},
+			[]Option{CreateParents()},
+			``,
+			ErrPathNotFound,
+		},
+		{
+			"create parents of scalar",
+			`{"a":1}`,
+			jsondiff.Patch{{Type: jsondiff.OperationAdd, Path: "/a/b/c", Value: 1}},
+			[]Option{CreateParents()},
+			``,
+			ErrTypeMismatch,
+		},
+		{
+			"create parents only for add",
+			`{}`,
+			jsondiff.Patch{{Type: jsondiff.OperationReplace, Path: "/a/b", Value: 1}},
+			[]Option{CreateParents()},
+			``,
+			ErrPathNotFound,
+		},
+		{
+			"skip missing remove",
+			`{"a":[1],"b":2}`,
+			jsondiff.Patch{
+				{Type: jsondiff.OperationRemove, Path: "/c"},
+				{Type: jsondiff.OperationRemove, Path: "/a/1"},
+				{Type: jsondiff.OperationRemove, Path: "/d/e"},
+				{Type: jsondiff.OperationRemove, Path: "/b"},
+			},
+			[]Option{SkipMissingRemove()},
+			`{"a":[1]}`,
+			nil,
+		},
+		{
+			"skip missing remove type mismatch",
+			`{"a":1}`,
+			jsondiff.Patch{{Type: jsondiff.OperationRemove, Path: "/a/b"}},
+			[]Option{SkipMissingRemove()},
+			``,
+			ErrTypeMismatch,
+		},
+		{
+			"ignore test failures",
+			`{"a":{"b":1},"c":[1,2]}`,
+			jsondiff.Patch{
+				{Type: jsondiff.OperationTest, Path: "/a/b", Value: 2},
+				{Type: jsondiff.OperationTest, Path: "/c/5", Value: 1},
+				{Type: jsondiff.OperationReplace, Path: "/a/b", Value: 3},
+			},
+			[]Option{IgnoreTestFailures("/a/b", "/c/*")},
+			`{"a":{"b":3},"c":[1,2]}`,
+			nil,
+		},
+		{
+			"ignore test failures on other paths",
+			`{"a":1,"b":1}`,
+			jsondiff.Patch{{Type: jsondiff.OperationTest, Path: "/b", Value: 2}},
+			[]Option{IgnoreTestFailures("/a")},
+			``,
+			ErrTestFailed,
+		},
+		{
+			"ignore all test failures",
+			`{"a":1}`,
+			jsondiff.Patch{
+				{Type: jsondiff.OperationTest, Path: "/a", Value: 2},
+				{Type: jsondiff.OperationTest, Path: "/b", Value: 2},
+			},
+			[]Option{IgnoreTestFailures()},
+			`{"a":1}`,
+			nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc any
+			if err := json.Unmarshal([]byte(tc.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(doc, tc.patch, tc.opts...)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("got error %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want any
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
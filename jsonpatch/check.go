@@ -0,0 +1,71 @@
+package jsonpatch
+
+import (
+	"github.com/wI2L/jsondiff"
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// A CheckResult reports the outcome of an
+// operation of a patch checked against a
+// document with the Check function.
+type CheckResult struct {
+	// Op is the operation checked, and Index
+	// its position in the patch.
+	Op    jsondiff.Operation
+	Index int
+
+	// Err is nil if the operation can be applied.
+	// Otherwise, it is an error of type *ApplyError
+	// that wraps one of ErrTestFailed, ErrPathNotFound,
+	// ErrTypeMismatch or jsondiff.ErrInvalidOperation.
+	Err error
+
+	// Pointer is the location of the document at
+	// which the operation failed, or its path if
+	// it can be applied.
+	Pointer string
+
+	// Value is a copy of the value found at Pointer
+	// before the operation, and Found reports whether
+	// such a value exists.
+	Value any
+	Found bool
+}
+
+// Applied returns whether the operation can be applied.
+func (r CheckResult) Applied() bool {
+	return r.Err == nil
+}
+
+// Check reports whether each operation of the patch can be
+// applied to the document doc, without failing fast. Unlike
+// Apply, a failed operation does not stop the application
+// of the patch: it is skipped, and the following operations
+// are checked against the document patched by the previous
+// operations that succeeded. The returned slice contains
+// one result per operation, in the order of the patch.
+//
+// The document is never modified, and the options are
+// the same as the ones of the Apply function. An error
+// is returned only if the document cannot be converted
+// to its JSON representation.
+func Check(doc any, patch jsondiff.Patch, opts ...Option) ([]CheckResult, error) {
+	rs, err := engine.NewApplier(options(opts)).Check(doc, operations(patch))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]CheckResult, len(rs))
+	for i, r := range rs {
+		res[i] = CheckResult{
+			Op:      patch[i],
+			Index:   i,
+			Pointer: r.Pointer,
+			Value:   r.Value,
+			Found:   r.Found,
+		}
+		if r.Failure != nil {
+			res[i].Err = newError(r.Failure, patch)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wI2L/jsondiff"
+)
+
+func TestCheck(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"a":{"b":1},"c":[1,2],"d":"foo"}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationTest, Path: "/a/b", Value: 2},
+		{Type: jsondiff.OperationReplace, Path: "/a/b", Value: 3},
+		{Type: jsondiff.OperationRemove, Path: "/x/y"},
+		{Type: jsondiff.OperationAdd, Path: "/d/e", Value: 1},
+		{Type: jsondiff.OperationMove, From: "/c/0", Path: "/c/5"},
+		{Type: jsondiff.OperationRemove, Path: "/c/0"},
+		{Type: jsondiff.OperationTest, Path: "/c", Value: []any{2}},
+		{Type: jsondiff.OperationCopy, From: "/c", Path: "a"},
+	}
+	got, err := Check(doc, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		err   error
+		ptr   string
+		value any
+		found bool
+	}{
+		{ErrTestFailed, "/a/b", 1.0, true},
+		{nil, "/a/b", 1.0, true},
+		{ErrPathNotFound, "/x", nil, false},
+		{ErrTypeMismatch, "/d/e", nil, false},
+		{ErrPathNotFound, "/c/5", nil, false},
+		{nil, "/c/0", 1.0, true},
+		{nil, "/c", []any{2.0}, true},
+		{jsondiff.ErrInvalidOperation, "a", nil, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i, r := range got {
+		w := want[i]
+		if r.Index != i || !reflect.DeepEqual(r.Op, patch[i]) {
+			t.Errorf("result #%d: unexpected operation %v at index %d", i, r.Op, r.Index)
+		}
+		if w.err == nil {
+			if !r.Applied() {
+				t.Errorf("result #%d: unexpected error: %v", i, r.Err)
+			}
+		} else if !errors.Is(r.Err, w.err) {
+			t.Errorf("result #%d: got error %v, want %v", i, r.Err, w.err)
+		}
+		if r.Pointer != w.ptr {
+			t.Errorf("result #%d: got pointer %q, want %q", i, r.Pointer, w.ptr)
+		}
+		if r.Found != w.found || !reflect.DeepEqual(r.Value, w.value) {
+			t.Errorf("result #%d: got value %v (found: %t), want %v (found: %t)", i, r.Value, r.Found, w.value, w.found)
+		}
+	}
+	// The document must not be modified.
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `{"a":{"b":1},"c":[1,2],"d":"foo"}` {
+		t.Errorf("document was modified: %s", s)
+	}
+}
+
+func TestCheck_options(t *testing.T) {
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationTest, Path: "/a", Value: 1},
+		{Type: jsondiff.OperationAdd, Path: "/b/c", Value: 1},
+		{Type: jsondiff.OperationRemove, Path: "/d"},
+	}
+	res, err := Check(map[string]any{}, patch, IgnoreTestFailures(), CreateParents(), SkipMissingRemove())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range res {
+		if !r.Applied() {
+			t.Errorf("unexpected error: %v", r.Err)
+		}
+	}
+	if _, err := Check(struct{}{}, patch); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
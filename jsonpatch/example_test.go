@@ -0,0 +1,67 @@
+package jsonpatch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/wI2L/jsondiff"
+	"github.com/wI2L/jsondiff/jsonpatch"
+)
+
+func ExampleApply() {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":[1,2,3],"b":{"foo":"bar"}}`), &doc); err != nil {
+		log.Fatal(err)
+	}
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationTest, Path: "/b/foo", Value: "bar"},
+		{Type: jsondiff.OperationMove, From: "/b", Path: "/c"},
+		{Type: jsondiff.OperationAdd, Path: "/a/-", Value: 4},
+	}
+	newDoc, err := jsonpatch.Apply(doc, patch)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := json.Marshal(newDoc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(b))
+
+	_, err = jsonpatch.Apply(doc, jsondiff.Patch{
+		{Type: jsondiff.OperationRemove, Path: "/b/baz"},
+	})
+	fmt.Println(err)
+	// Output:
+	// {"a":[1,2,3,4],"c":{"foo":"bar"}}
+	// jsonpatch: operation #0 (remove "/b/baz"): path not found at "/b/baz"
+}
+
+func ExampleCheck() {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":[1,2],"b":{"foo":"bar"}}`), &doc); err != nil {
+		log.Fatal(err)
+	}
+	patch := jsondiff.Patch{
+		{Type: jsondiff.OperationTest, Path: "/b/foo", Value: "baz"},
+		{Type: jsondiff.OperationReplace, Path: "/a/1", Value: 3},
+		{Type: jsondiff.OperationRemove, Path: "/c/d"},
+	}
+	results, err := jsonpatch.Check(doc, patch)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Applied() {
+			fmt.Printf("#%d: ok, found %v\n", r.Index, r.Value)
+		} else {
+			fmt.Printf("#%d: %s at %q, found %v\n", r.Index, errors.Unwrap(r.Err), r.Pointer, r.Value)
+		}
+	}
+	// Output:
+	// #0: test failed at "/b/foo", found bar
+	// #1: ok, found 2
+	// #2: path not found at "/c", found <nil>
+}
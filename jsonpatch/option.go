@@ -0,0 +1,48 @@
+package jsonpatch
+
+import "github.com/wI2L/jsondiff/internal/engine"
+
+// An Option changes the default behavior of the
+// Apply and Check functions.
+type Option func(*engine.Options)
+
+func options(opts []Option) engine.Options {
+	var o engine.Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// CreateParents instructs to create the missing objects
+// that are parents of the location of an add operation,
+// instead of failing. Missing array elements are never
+// created.
+func CreateParents() Option {
+	return func(o *engine.Options) { o.CreateParents = true }
+}
+
+// SkipMissingRemove instructs to ignore the remove
+// operations whose location does not exist, instead
+// of failing.
+func SkipMissingRemove() Option {
+	return func(o *engine.Options) { o.SkipMissing = true }
+}
+
+// IgnoreTestFailures instructs to ignore the failures of
+// the test operations located at the given pointers, or
+// patterns as for jsondiff.Ignores. A test fails if its
+// location does not exist, or if the value found is not
+// equal. If no pointer is given, the failures of all test
+// operations are ignored.
+func IgnoreTestFailures(ptrs ...string) Option {
+	return func(o *engine.Options) {
+		if len(ptrs) == 0 {
+			o.IgnoreTests = true
+			return
+		}
+		o.TestPointers = append(o.TestPointers, ptrs...)
+	}
+}
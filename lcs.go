@@ -1,41 +1,267 @@
 package jsondiff
 
+import (
+	"cmp"
+	"slices"
+)
+
 // lcs computes the longest common subsequence of two
 // slices and returns the index pairs of the LCS, that
 // is, the indices into the source and target slices
-// where the LCS items are located
-func lcs(src, tgt []interface{}) [][2]int {
-	t := make([][]int, len(src)+1)
+// where the LCS items are located.
+// It implements the linear space variant of the Myers
+// difference algorithm, which runs in O((N+M)D) time,
+// where D is the size of the shortest edit script.
+// See "An O(ND) Difference Algorithm and Its Variations",
+// Eugene W. Myers, Algorithmica 1, 1986.
+//
+// The search of the middle snakes compares the same
+// elements several times, so for large arrays, the digests
+// of the elements are computed once with the hasher, on
+// first use. Most comparisons of unequal elements then only
+// compare two integers, and equal digests are confirmed with
+// a deep equality check to rule out collisions.
+func lcs(src, tgt []interface{}, h *hasher) [][2]int {
+	m := newMyers(src, tgt, h)
+	return m.lcs(len(src), len(tgt))
+}
+
+// newMyers returns the state of the computation of the
+// LCS of the src and tgt slices.
+func newMyers(src, tgt []interface{}, h *hasher) myers {
+	m := myers{
+		equal: func(i, j int) bool {
+			return deepEqual(src[i], tgt[j])
+		},
+	}
+	m.match = m.equal
+
+	// Hashing an element walks all its values, which is
+	// only worth it if the element is compared several
+	// times, as it happens with large arrays.
+	if len(src)+len(tgt) >= lcsDigestMinLen {
+		sd := newLazyDigests(src, h)
+		td := newLazyDigests(tgt, h)
+
+		m.match = func(i, j int) bool {
+			// Scalar values are cheaper to compare
+			// directly than to hash.
+			if !isContainer(src[i]) || !isContainer(tgt[j]) {
+				return deepEqual(src[i], tgt[j])
+			}
+			return sd.get(i) == td.get(j) && deepEqual(src[i], tgt[j])
+		}
+	}
+	return m
+}
+
+// lcsDigestMinLen is the minimum combined length of
+// two arrays for the LCS to compare elements by digest.
+const lcsDigestMinLen = 32
 
-	for i := 0; i <= len(src); i++ {
-		t[i] = make([]int, len(tgt)+1)
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	default:
+		return false
 	}
-	for i := 1; i < len(t); i++ {
-		for j := 1; j < len(t[i]); j++ {
-			if deepEqual(src[i-1], tgt[j-1]) {
-				t[i][j] = t[i-1][j-1] + 1
+}
+
+// lazyDigests holds the digests of the elements of an
+// array, which are computed on first access.
+type lazyDigests struct {
+	arr     []interface{}
+	digests []uint64
+	done    []bool
+	h       *hasher
+}
+
+func newLazyDigests(arr []interface{}, h *hasher) lazyDigests {
+	return lazyDigests{
+		arr:     arr,
+		digests: make([]uint64, len(arr)),
+		done:    make([]bool, len(arr)),
+		h:       h,
+	}
+}
+
+func (l lazyDigests) get(i int) uint64 {
+	if !l.done[i] {
+		l.digests[i] = l.h.digest(l.arr[i], false)
+		l.done[i] = true
+	}
+	return l.digests[i]
+}
+
+// myers holds the state of the computation of the
+// LCS of two sequences of length n and m, whose
+// elements are compared by index. The equal function
+// is used to strip the common prefix and suffix, while
+// match is used for the search of the middle snakes.
+// If stop is not nil, it is called regularly and the
+// computation is abandoned if it returns true, in which
+// case the pairs are incomplete.
+type myers struct {
+	equal   func(i, j int) bool // single comparison
+	match   func(i, j int) bool // repeated comparisons
+	stop    func() bool         // interrupts the computation
+	vf      []int               // furthest reaching forward paths
+	vb      []int               // furthest reaching backward paths
+	off     int                 // offset of the diagonal zero
+	pairs   [][2]int
+	stopped bool
+}
+
+func (m *myers) lcs(n, mm int) [][2]int {
+	m.off = n + mm + 1
+	m.vf = make([]int, 2*m.off+1)
+	m.vb = make([]int, 2*m.off+1)
+	m.pairs = make([][2]int, 0, min(n, mm))
+
+	m.compare(0, n, 0, mm)
+
+	return m.pairs
+}
+
+// compare appends to the pairs the LCS of the source
+// elements in range [a0, a1) and target elements in
+// range [b0, b1), in ascending order.
+func (m *myers) compare(a0, a1, b0, b1 int) {
+	if m.stopped {
+		return
+	}
+	// Strip common prefix.
+	for a0 < a1 && b0 < b1 && m.equal(a0, b0) {
+		m.pairs = append(m.pairs, [2]int{a0, b0})
+		a0++
+		b0++
+	}
+	// Strip common suffix, the pairs are
+	// appended after those of the middle.
+	n := 0
+	for a0 < a1 && b0 < b1 && m.equal(a1-1, b1-1) {
+		a1--
+		b1--
+		n++
+	}
+	// If one of the ranges is empty, there is
+	// nothing more in common.
+	if a0 < a1 && b0 < b1 {
+		x, y, u, v := m.middleSnake(a0, a1, b0, b1)
+
+		m.compare(a0, x, b0, y)
+		for i := 0; i < u-x; i++ {
+			m.pairs = append(m.pairs, [2]int{x + i, y + i})
+		}
+		m.compare(u, a1, v, b1)
+	}
+	for i := 0; i < n; i++ {
+		m.pairs = append(m.pairs, [2]int{a1 + i, b1 + i})
+	}
+}
+
+// middleSnake finds the middle snake of an optimal edit
+// path between the source elements in range [a0, a1) and
+// the target elements in range [b0, b1), by searching for
+// the furthest reaching paths from both ends simultaneously.
+// It returns the coordinates of the start (x, y) and end
+// (u, v) of the snake, which may be empty.
+func (m *myers) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, mm := a1-a0, b1-b0
+	delta := n - mm
+	odd := delta&1 != 0
+	vf, vb, off := m.vf, m.vb, m.off
+
+	vf[off+1] = 0
+	vb[off+1] = 0
+
+	for d := 0; d <= (n+mm+1)/2; d++ {
+		if m.stop != nil && m.stop() {
+			m.stopped = true
+			return a0, b0, a0, b0
+		}
+		// Forward search, from the top-left corner.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1] // down
+			} else {
+				x = vf[off+k-1] + 1 // right
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < mm && m.match(a0+x, b0+y) {
+				x++
+				y++
+			}
+			vf[off+k] = x
+
+			// The backward diagonal that overlaps the
+			// forward diagonal k is delta-k.
+			if odd && k >= delta-(d-1) && k <= delta+(d-1) {
+				if x+vb[off+delta-k] >= n {
+					return a0 + sx, b0 + sy, a0 + x, b0 + y
+				}
+			}
+		}
+		// Backward search, from the bottom-right corner.
+		// The coordinates are relative to the end of
+		// the ranges.
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
 			} else {
-				t[i][j] = max(t[i-1][j], t[i][j-1])
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < mm && m.match(a1-x-1, b1-y-1) {
+				x++
+				y++
+			}
+			vb[off+k] = x
+
+			if !odd && k >= delta-d && k <= delta+d {
+				if x+vf[off+delta-k] >= n {
+					return a1 - x, b1 - y, a1 - sx, b1 - sy
+				}
 			}
 		}
 	}
-	i, j := len(src), len(tgt)
-	s := make([][2]int, 0, t[i][j])
-
-	for i > 0 && j > 0 {
-		switch {
-		case deepEqual(src[i-1], tgt[j-1]):
-			s = append(s, [2]int{i - 1, j - 1})
-			i--
-			j--
-		case t[i-1][j] > t[i][j-1]:
-			i--
-		default:
-			j--
+	// Unreachable, the paths always overlap.
+	panic("jsondiff: middle snake not found")
+}
+
+// lis computes the longest strictly increasing subsequence
+// of s and returns the indices into s of its elements.
+func lis(s []int) []int {
+	if len(s) == 0 {
+		return nil
+	}
+	// tails[k] is the index of the smallest tail
+	// of all increasing subsequences of length k+1.
+	tails := make([]int, 0, len(s))
+	prev := make([]int, len(s))
+
+	for i, v := range s {
+		k, _ := slices.BinarySearchFunc(tails, v, func(t, v int) int {
+			return cmp.Compare(s[t], v)
+		})
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
 		}
 	}
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+	r := make([]int, len(tails))
+	for i, k := len(r)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		r[i] = k
 	}
-	return s
+	return r
 }
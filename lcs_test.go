@@ -1,6 +1,7 @@
 package jsondiff
 
 import (
+	"math/rand"
 	"reflect"
 	"testing"
 )
@@ -76,10 +77,78 @@ func Test_lcs(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			pairs := lcs(tc.src, tc.tgt)
+			pairs := lcs(tc.src, tc.tgt, &hasher{})
 			if !reflect.DeepEqual(pairs, tc.pairs) {
 				t.Errorf("got %v, want %v", pairs, tc.pairs)
 			}
 		})
 	}
 }
+
+func Test_lis(t *testing.T) {
+	for _, tc := range []struct {
+		s   []int
+		idx []int
+	}{
+		{nil, nil},
+		{[]int{0}, []int{0}},
+		{[]int{0, 1, 2}, []int{0, 1, 2}},
+		{[]int{2, 1, 0}, []int{2}},
+		{[]int{3, 0, 1, 2}, []int{1, 2, 3}},
+		{[]int{1, 2, 3, 0}, []int{0, 1, 2}},
+		{[]int{2, 0, 3, 1, 4}, []int{1, 3, 4}},
+	} {
+		if idx := lis(tc.s); !reflect.DeepEqual(idx, tc.idx) {
+			t.Errorf("lis(%v): got %v, want %v", tc.s, idx, tc.idx)
+		}
+	}
+}
+
+func Test_lcs_random(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 1000; i++ {
+		src := randomSlice(r, r.Intn(20), 4)
+		tgt := randomSlice(r, r.Intn(20), 4)
+
+		pairs := lcs(src, tgt, &hasher{})
+		for k, p := range pairs {
+			if !deepEqual(src[p[0]], tgt[p[1]]) {
+				t.Fatalf("pair %v of %v and %v is not a match", p, src, tgt)
+			}
+			if k > 0 && (p[0] <= pairs[k-1][0] || p[1] <= pairs[k-1][1]) {
+				t.Fatalf("pairs %v of %v and %v are not increasing", pairs, src, tgt)
+			}
+		}
+		if l, want := len(pairs), lcsLength(src, tgt); l != want {
+			t.Fatalf("got LCS of length %d, want %d for %v and %v", l, want, src, tgt)
+		}
+	}
+}
+
+// lcsLength returns the length of the LCS of two
+// slices using the dynamic programming approach.
+func lcsLength(src, tgt []interface{}) int {
+	prev := make([]int, len(tgt)+1)
+	curr := make([]int, len(tgt)+1)
+
+	for i := 1; i <= len(src); i++ {
+		for j := 1; j <= len(tgt); j++ {
+			if deepEqual(src[i-1], tgt[j-1]) {
+				curr[j] = prev[j-1] + 1
+			} else {
+				curr[j] = max(prev[j], curr[j-1])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(tgt)]
+}
+
+func randomSlice(r *rand.Rand, n, alphabet int) []interface{} {
+	s := make([]interface{}, n)
+	for i := range s {
+		s[i] = float64(r.Intn(alphabet))
+	}
+	return s
+}
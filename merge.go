@@ -2,6 +2,8 @@ package jsondiff
 
 import (
 	"encoding/json"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 // MergePatch returns a JSON Merge Patch (RFC 7386)
@@ -53,7 +55,7 @@ func mergePatch(src, tgt interface{}) interface{} {
 	// or both are not objects, the patch replaces the entire
 	// source with the target.
 	// https://datatracker.ietf.org/doc/html/rfc7386#section-2
-	if jsonTypeSwitch(src) != jsonObject || jsonTypeSwitch(tgt) != jsonObject {
+	if engine.TypeOf(src) != engine.Object || engine.TypeOf(tgt) != engine.Object {
 		return tgt
 	}
 	sm := src.(map[string]interface{})
@@ -0,0 +1,303 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// A Conflict represents concurrent changes of the same
+// location of a document, or of a location and one of its
+// descendants, that cannot be combined, or that depend on
+// the order in which they are applied.
+type Conflict struct {
+	// Pointer is the location of the conflict, which is
+	// the common ancestor of the changed locations.
+	Pointer string
+
+	// Base, Ours and Theirs are the values located at
+	// Pointer in the base document and its two modified
+	// versions. A value is nil if it is a JSON null, or
+	// if it does not exist, in which case the operations
+	// that added or removed it tell them apart. They are
+	// not set by the Patch.Conflicts method.
+	Base   any
+	Ours   any
+	Theirs any
+
+	// OurOps and TheirOps are the conflicting operations
+	// of each side.
+	OurOps   Patch
+	TheirOps Patch
+}
+
+// Merge3 merges the changes made by two sides to a common
+// base document. The differences between base and ours, and
+// between base and theirs, are compared with the given options,
+// like the Compare function does, then the changes of both
+// sides are applied to a copy of the base document, and the
+// merged document is returned.
+//
+// The members of objects are merged individually. Arrays are
+// merged as a whole: if both sides change the same array, its
+// elements included, the changes conflict unless the resulting
+// arrays are equal. When the changes of both sides conflict,
+// the value of the base document is kept in the merged document
+// and a Conflict is reported, in the order of the pointers.
+// Identical changes made by both sides do not conflict.
+//
+// The StreamFunc option is ignored, since the patches of
+// both sides are required to merge their changes, and so
+// are the Invertible, MaxDepth and MaxOperations options,
+// which would make the changes, and their conflicts, coarser.
+// The Rationalize option may also replace a value changed by
+// one side as a whole, which then conflicts with any change
+// of the other side within that value.
+func Merge3(base, ours, theirs any, opts ...Option) (any, []Conflict, error) {
+	var d Differ
+	d.applyOpts(opts...)
+
+	d.opts.stream = nil
+	d.opts.invertible = false
+	d.opts.maxDepth = 0
+	d.opts.maxOps = 0
+
+	if d.opts.marshal == nil {
+		d.opts.marshal = json.Marshal
+	}
+	if d.opts.unmarshal == nil {
+		d.opts.unmarshal = json.Unmarshal
+	}
+	var m merger
+
+	bi, _, err := marshalUnmarshal(base, d.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.base = bi
+	for i, v := range []any{ours, theirs} {
+		vi, vb, err := marshalUnmarshal(v, d.opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.Reset()
+		d.targetBytes = vb
+		d.Compare(bi, vi)
+		if d.err != nil {
+			return nil, nil, d.err
+		}
+		m.docs[i] = vi
+		m.patches[i] = slices.Clone(d.patch)
+	}
+	return m.merge()
+}
+
+// A merger merges the changes of two
+// modified versions of a base document.
+type merger struct {
+	base    any
+	docs    [2]any   // ours, theirs
+	patches [2]Patch // base to ours, base to theirs
+}
+
+// A change is a location of the base document whose
+// value is changed by one side, along with the
+// operations that change it.
+type change struct {
+	ptr engine.ParsedPointer
+	ops Patch
+}
+
+func (m *merger) merge() (any, []Conflict, error) {
+	var changes [2][]change
+	for i := range m.patches {
+		cs, err := m.changes(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		changes[i] = cs
+	}
+	// Find the locations changed by both sides, and
+	// keep the outermost ones.
+	var conflicts []engine.ParsedPointer
+	for _, c1 := range changes[0] {
+		for _, c2 := range changes[1] {
+			if !isPrefix(c1.ptr, c2.ptr) && !isPrefix(c2.ptr, c1.ptr) {
+				continue
+			}
+			p := c1.ptr
+			if len(c2.ptr.Tokens) < len(p.Tokens) {
+				p = c2.ptr
+			}
+			v1, ok1 := m.value(0, p)
+			v2, ok2 := m.value(1, p)
+			if ok1 == ok2 && engine.Equal(v1, v2) {
+				// Both sides made the same change.
+				continue
+			}
+			conflicts = append(conflicts, p)
+		}
+	}
+	conflicts = outermost(conflicts)
+
+	doc := engine.DeepCopy(m.base)
+	for i, cs := range changes {
+		for _, c := range cs {
+			if slices.ContainsFunc(conflicts, func(p engine.ParsedPointer) bool { return isPrefix(p, c.ptr) }) {
+				continue
+			}
+			if err := m.set(&doc, i, c.ptr); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	var res []Conflict
+	for _, p := range conflicts {
+		c := Conflict{Pointer: p.Str}
+		c.Base, _ = engine.LookupValue(m.base, p.Str)
+		c.Ours, _ = m.value(0, p)
+		c.Theirs, _ = m.value(1, p)
+
+		for i, cs := range changes {
+			var ops Patch
+			for _, ch := range cs {
+				if isPrefix(p, ch.ptr) || isPrefix(ch.ptr, p) {
+					ops = append(ops, ch.ops...)
+				}
+			}
+			if i == 0 {
+				c.OurOps = ops
+			} else {
+				c.TheirOps = ops
+			}
+		}
+		res = append(res, c)
+	}
+	return doc, res, nil
+}
+
+// changes returns the locations of the base document
+// changed by the side i, in the order of the patch.
+func (m *merger) changes(i int) ([]change, error) {
+	var cs []change
+
+	for _, op := range m.patches[i] {
+		var ptrs []string
+		switch op.Type {
+		case OperationTest:
+			continue
+		case OperationMove:
+			ptrs = []string{op.From, op.Path}
+		default:
+			ptrs = []string{op.Path}
+		}
+		var units []engine.ParsedPointer
+		for _, s := range ptrs {
+			p, err := engine.ParsePath(s)
+			if err != nil {
+				return nil, err
+			}
+			p = m.unit(p)
+			if !slices.ContainsFunc(units, func(u engine.ParsedPointer) bool { return slices.Equal(u.Tokens, p.Tokens) }) {
+				units = append(units, p)
+			}
+		}
+		for _, p := range units {
+			idx := slices.IndexFunc(cs, func(c change) bool { return slices.Equal(c.ptr.Tokens, p.Tokens) })
+			if idx == -1 {
+				cs = append(cs, change{ptr: p})
+				idx = len(cs) - 1
+			}
+			cs[idx].ops = append(cs[idx].ops, op)
+		}
+	}
+	// Drop the changes nested in other changes of the
+	// same side, such as the elements of an array.
+	ptrs := make([]engine.ParsedPointer, len(cs))
+	for j, c := range cs {
+		ptrs[j] = c.ptr
+	}
+	ptrs = outermost(ptrs)
+
+	res := cs[:0]
+	for _, c := range cs {
+		if slices.ContainsFunc(ptrs, func(p engine.ParsedPointer) bool { return slices.Equal(p.Tokens, c.ptr.Tokens) }) {
+			res = append(res, c)
+			continue
+		}
+		for j := range res {
+			if isPrefix(res[j].ptr, c.ptr) {
+				res[j].ops = append(res[j].ops, c.ops...)
+			}
+		}
+	}
+	return res, nil
+}
+
+// unit returns the location changed as a whole by a
+// change of the location p of the base document, which
+// is the outermost array that contains it, if any.
+func (m *merger) unit(p engine.ParsedPointer) engine.ParsedPointer {
+	cur := m.base
+	for i, tok := range p.Tokens {
+		switch c := cur.(type) {
+		case []any:
+			return ancestor(p, i)
+		case map[string]any:
+			v, ok := c[tok]
+			if !ok {
+				return p
+			}
+			cur = v
+		default:
+			return p
+		}
+	}
+	return p
+}
+
+// value returns the value located at p in the
+// document of the side i, and whether it exists.
+func (m *merger) value(i int, p engine.ParsedPointer) (any, bool) {
+	v, err := engine.GetValue(m.docs[i], p)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// set sets the value located at p in the document doc
+// to the value of the side i, or removes it if the side
+// does not have one.
+func (m *merger) set(doc *any, i int, p engine.ParsedPointer) error {
+	v, ok := m.value(i, p)
+	if !ok {
+		_, err := engine.RemoveValue(doc, p)
+		if err != nil && !errors.Is(err.Err, engine.ErrPathNotFound) {
+			return err
+		}
+		return nil
+	}
+	if err := engine.AddValue(doc, p, engine.DeepCopy(v)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// outermost returns the pointers that are not
+// descendants of other pointers, in order.
+func outermost(ptrs []engine.ParsedPointer) []engine.ParsedPointer {
+	slices.SortFunc(ptrs, func(a, b engine.ParsedPointer) int {
+		return slices.Compare(a.Tokens, b.Tokens)
+	})
+	res := ptrs[:0]
+	for _, p := range ptrs {
+		if len(res) != 0 && isPrefix(res[len(res)-1], p) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
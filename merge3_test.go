@@ -0,0 +1,211 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts []string // pointer, base, ours, theirs
+	}{
+		{
+			"distinct members",
+			`{"a":1,"b":2,"c":{"d":3,"e":4}}`,
+			`{"a":10,"b":2,"c":{"d":3,"e":4}}`,
+			`{"a":1,"c":{"d":3,"e":40},"f":5}`,
+			`{"a":10,"c":{"d":3,"e":40},"f":5}`,
+			nil,
+		},
+		{
+			"identical changes",
+			`{"a":1,"b":[1,2]}`,
+			`{"a":2,"b":[1,2,3]}`,
+			`{"a":2,"b":[1,2,3],"c":true}`,
+			`{"a":2,"b":[1,2,3],"c":true}`,
+			nil,
+		},
+		{
+			"same member",
+			`{"a":1,"b":2}`,
+			`{"a":2,"b":2}`,
+			`{"a":3,"b":3}`,
+			`{"a":1,"b":3}`,
+			[]string{"/a", "1", "2", "3"},
+		},
+		{
+			"removed and changed",
+			`{"a":{"b":1,"c":2},"d":1}`,
+			`{"d":1}`,
+			`{"a":{"b":1,"c":3},"d":2}`,
+			`{"a":{"b":1,"c":2},"d":2}`,
+			[]string{"/a", `{"b":1,"c":2}`, "null", `{"b":1,"c":3}`},
+		},
+		{
+			"added with different values",
+			`{}`,
+			`{"a":{"b":1}}`,
+			`{"a":{"b":2}}`,
+			`{}`,
+			[]string{"/a", "null", `{"b":1}`, `{"b":2}`},
+		},
+		{
+			"array changed by one side",
+			`{"a":[1,2,3],"b":[{"c":1}]}`,
+			`{"a":[1,3],"b":[{"c":1}]}`,
+			`{"a":[1,2,3],"b":[{"c":2}],"d":1}`,
+			`{"a":[1,3],"b":[{"c":2}],"d":1}`,
+			nil,
+		},
+		{
+			"array changed by both sides",
+			`{"a":[1,2,3],"b":1}`,
+			`{"a":[1,3],"b":1}`,
+			`{"a":[0,1,2,3],"b":2}`,
+			`{"a":[1,2,3],"b":2}`,
+			[]string{"/a", "[1,2,3]", "[1,3]", "[0,1,2,3]"},
+		},
+		{
+			"nested conflicts",
+			`{"a":{"b":{"c":1,"d":1}}}`,
+			`{"a":{"b":{"c":2,"d":2}}}`,
+			`{"a":{"b":3}}`,
+			`{"a":{"b":{"c":1,"d":1}}}`,
+			[]string{"/a/b", `{"c":1,"d":1}`, `{"c":2,"d":2}`, "3"},
+		},
+		{
+			"escaped pointers",
+			`{"a/b":1,"c~d":{"e":1}}`,
+			`{"a/b":2,"c~d":{"e":1}}`,
+			`{"a/b":3,"c~d":{"e":2}}`,
+			`{"a/b":1,"c~d":{"e":2}}`,
+			[]string{"/a~1b", "1", "2", "3"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var base, ours, theirs any
+			for _, v := range []struct {
+				s string
+				v *any
+			}{{tc.base, &base}, {tc.ours, &ours}, {tc.theirs, &theirs}} {
+				if err := json.Unmarshal([]byte(v.s), v.v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, conflicts, err := Merge3(base, ours, theirs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonStringEqual(t, mustMarshal(t, got), tc.want) {
+				t.Errorf("got %s, want %s", mustMarshal(t, got), tc.want)
+			}
+			if len(conflicts)*4 != len(tc.conflicts) {
+				t.Fatalf("got %d conflicts, want %d: %v", len(conflicts), len(tc.conflicts)/4, conflicts)
+			}
+			for i, c := range conflicts {
+				want := tc.conflicts[i*4 : i*4+4]
+				if c.Pointer != want[0] {
+					t.Errorf("got conflict pointer %q, want %q", c.Pointer, want[0])
+				}
+				for j, v := range []any{c.Base, c.Ours, c.Theirs} {
+					if !jsonStringEqual(t, mustMarshal(t, v), want[j+1]) {
+						t.Errorf("got conflict value #%d %s, want %s", j, mustMarshal(t, v), want[j+1])
+					}
+				}
+				if len(c.OurOps) == 0 || len(c.TheirOps) == 0 {
+					t.Errorf("expected conflicting operations of both sides")
+				}
+			}
+			// The base document must not be modified.
+			if s := mustMarshal(t, base); !jsonStringEqual(t, s, tc.base) {
+				t.Errorf("base document was modified: %s", s)
+			}
+		})
+	}
+}
+
+func TestMerge3_options(t *testing.T) {
+	base := map[string]any{"a": []any{1, 2}, "b": 1}
+	ours := map[string]any{"a": []any{2, 1}, "b": 1}
+	theirs := map[string]any{"a": []any{1, 2, 3}, "b": 2}
+
+	got, conflicts, err := Merge3(base, ours, theirs, Equivalent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("unexpected conflicts: %v", conflicts)
+	}
+	if s := mustMarshal(t, got); s != `{"a":[1,2,3],"b":2}` {
+		t.Errorf("got %s", s)
+	}
+	// The operations are not streamed.
+	var n int
+	got, _, err = Merge3(base, ours, theirs, Equivalent(), StreamFunc(func(Operation) error {
+		n++
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d streamed operations, want none", n)
+	}
+	if s := mustMarshal(t, got); s != `{"a":[1,2,3],"b":2}` {
+		t.Errorf("got %s", s)
+	}
+	if _, _, err := Merge3(base, ours, make(chan int)); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestMerge3_disjointOptions(t *testing.T) {
+	base := map[string]any{
+		"a": map[string]any{"b": []any{1, 2, 3}, "c": "x", "d": map[string]any{"e": 1, "f": 2}},
+		"g": []any{map[string]any{"h": 1}, map[string]any{"h": 2}},
+	}
+	ours := map[string]any{
+		"a": map[string]any{"b": []any{1, 2, 3}, "c": "y", "d": map[string]any{"e": 1, "f": 2}},
+		"g": []any{map[string]any{"h": 1}, map[string]any{"h": 20}},
+	}
+	theirs := map[string]any{
+		"a": map[string]any{"b": []any{1, 2, 3}, "c": "x", "d": map[string]any{"e": 10, "f": 2}},
+		"g": []any{map[string]any{"h": 1}, map[string]any{"h": 2}},
+		"i": true,
+	}
+	want := `{"a":{"b":[1,2,3],"c":"y","d":{"e":10,"f":2}},"g":[{"h":1},{"h":20}],"i":true}`
+
+	for _, opts := range [][]Option{
+		nil,
+		{Invertible(), Rationalize()},
+		{MaxOperations(1)},
+		{MaxDepth(1)},
+		{Invertible(), Rationalize(), MaxOperations(1), MaxDepth(1)},
+	} {
+		got, conflicts, err := Merge3(base, ours, theirs, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(conflicts) != 0 {
+			t.Errorf("unexpected conflicts: %v", conflicts)
+		}
+		if s := mustMarshal(t, got); !jsonStringEqual(t, s, want) {
+			t.Errorf("got %s, want %s", s, want)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
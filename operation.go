@@ -1,9 +1,13 @@
 package jsondiff
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"unsafe"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 // JSON Patch operation types.
@@ -18,6 +22,13 @@ const (
 	OperationTest    = "test"
 )
 
+// ErrInvalidOperation is returned when an operation
+// is malformed, such as an unknown operation type, an
+// invalid pointer, or a move to a child of its origin.
+var ErrInvalidOperation = engine.ErrInvalidOperation
+
+const emptyPointer = ""
+
 const (
 	fromFieldLen  = 10 // ,"from":""
 	valueFieldLen = 9  // ,"value":
@@ -35,8 +46,19 @@ type Operation struct {
 	From     string      `json:"from,omitempty"`
 	Path     string      `json:"path"`
 	valueLen int
+	absent   uint8 // members absent from the decoded operation
+	withOld  bool  // marshal the old value
 }
 
+// Members of an operation that may be absent
+// from its JSON representation.
+const (
+	absentOp uint8 = 1 << iota
+	absentPath
+	absentFrom
+	absentValue
+)
+
 // MarshalJSON implements the json.Marshaler interface.
 func (null) MarshalJSON() ([]byte, error) {
 	return []byte("null"), nil
@@ -65,9 +87,108 @@ func (o Operation) MarshalJSON() ([]byte, error) {
 	if !o.hasFrom() {
 		o.From = emptyPointer
 	}
+	if o.withOld && o.marshalWithOld() {
+		return json.Marshal(struct {
+			op
+			Old interface{} `json:"old"`
+		}{op(o), o.OldValue})
+	}
 	return json.Marshal(op(o))
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The members of the operation are matched exactly, and the
+// unknown members are ignored. Unlike a value set to null, a
+// missing value member is reported by the Validate method
+// of the patch. The "old" member of a replace or remove
+// operation, emitted with the OldValues option, is decoded
+// into the OldValue field, and marshaled back.
+//
+// Since the UseNumber option of a json.Decoder does not apply
+// to the values of the operation, their numbers are decoded
+// as float64, unless they are integers that a float64 cannot
+// represent exactly, which are decoded as json.Number.
+func (o *Operation) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("%w: operation must be a JSON object", ErrInvalidOperation)
+	}
+	*o = Operation{}
+
+	for _, f := range []struct {
+		name string
+		dst  *string
+		flag uint8
+	}{
+		{"op", &o.Type, absentOp},
+		{"path", &o.Path, absentPath},
+		{"from", &o.From, absentFrom},
+	} {
+		raw, ok := m[f.name]
+		if !ok {
+			o.absent |= f.flag
+			continue
+		}
+		if err := json.Unmarshal(raw, f.dst); err != nil || raw[0] != '"' {
+			return fmt.Errorf("%w: member %q must be a string", ErrInvalidOperation, f.name)
+		}
+	}
+	if raw, ok := m["old"]; ok && o.marshalWithOld() {
+		if err := unmarshalValue(raw, &o.OldValue); err != nil {
+			return err
+		}
+		o.withOld = true
+	}
+	raw, ok := m["value"]
+	if !ok {
+		o.absent |= absentValue
+		return nil
+	}
+	return unmarshalValue(raw, &o.Value)
+}
+
+// unmarshalValue decodes the JSON value b into v, and keeps
+// the precision of the integers beyond the range of float64.
+func unmarshalValue(b []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	*v = exactNumbers(*v)
+	return nil
+}
+
+// exactNumbers replaces the numbers of v that a float64
+// represents exactly, or that are not integers, by their
+// float64 value.
+func exactNumbers(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(string(val), ".eE") {
+			i, err := val.Int64()
+			if err != nil {
+				return val // out of the range of int64
+			}
+			return engine.NativeInt(i)
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val
+	case map[string]any:
+		for k, mv := range val {
+			val[k] = exactNumbers(mv)
+		}
+	case []any:
+		for i, av := range val {
+			val[i] = exactNumbers(av)
+		}
+	}
+	return v
+}
+
 // jsonLength returns the length in bytes that the
 // operation would occupy when marshaled to JSON.
 func (o Operation) jsonLength() int {
@@ -91,6 +212,15 @@ func (o Operation) hasFrom() bool {
 	}
 }
 
+func (o Operation) marshalWithOld() bool {
+	switch o.Type {
+	case OperationReplace, OperationRemove:
+		return true
+	default:
+		return false
+	}
+}
+
 func (o Operation) marshalWithValue() bool {
 	switch o.Type {
 	case OperationAdd, OperationReplace, OperationTest:
@@ -100,6 +230,12 @@ func (o Operation) marshalWithValue() bool {
 	}
 }
 
+// operation returns the part of the operation
+// that the engine uses to apply it.
+func (o Operation) operation() engine.Operation {
+	return engine.Operation{Type: o.Type, From: o.From, Path: o.Path, Value: o.Value}
+}
+
 func (p *Patch) remove(idx int) Patch {
 	return (*p)[:idx+copy((*p)[idx:], (*p)[idx+1:])]
 }
@@ -1,6 +1,10 @@
 package jsondiff
 
 import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -89,6 +93,45 @@ func TestOperation_MarshalJSON(t *testing.T) {
 			},
 			`{"op":"move","from":"/bar","path":"/baz"}`,
 		},
+		{
+			// Old values are marshaled only if requested.
+			Operation{
+				Type:     OperationReplace,
+				Path:     "/foo",
+				Value:    1,
+				OldValue: 0,
+			},
+			`{"value":1,"op":"replace","path":"/foo"}`,
+		},
+		{
+			Operation{
+				Type:     OperationReplace,
+				Path:     "/foo",
+				Value:    1,
+				OldValue: map[string]any{"bar": 0},
+				withOld:  true,
+			},
+			`{"value":1,"op":"replace","path":"/foo","old":{"bar":0}}`,
+		},
+		{
+			Operation{
+				Type:    OperationRemove,
+				Path:    "/foo",
+				withOld: true,
+			},
+			`{"op":"remove","path":"/foo","old":null}`,
+		},
+		{
+			// Add operation should NEVER be marshaled with an old value.
+			Operation{
+				Type:     OperationAdd,
+				Path:     "/foo",
+				Value:    1,
+				OldValue: 0,
+				withOld:  true,
+			},
+			`{"value":1,"op":"add","path":"/foo"}`,
+		},
 	} {
 		b, err := tc.Op.MarshalJSON()
 		if err != nil {
@@ -167,3 +210,111 @@ func typeNilIface() interface{} {
 
 	return p
 }
+
+func TestOperation_UnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+		want Operation
+		err  bool
+	}{
+		{
+			"null value",
+			`{"op":"add","path":"/a","value":null}`,
+			Operation{Type: OperationAdd, Path: "/a", absent: absentFrom},
+			false,
+		},
+		{
+			"missing value",
+			`{"op":"add","path":"/a"}`,
+			Operation{Type: OperationAdd, Path: "/a", absent: absentFrom | absentValue},
+			false,
+		},
+		{
+			"unknown members",
+			`{"op":"move","from":"/a","path":"/b","OP":"remove","extra":1}`,
+			Operation{Type: OperationMove, From: "/a", Path: "/b", absent: absentValue},
+			false,
+		},
+		{
+			"value",
+			`{"path":"","value":{"a":[1,"b",true]}}`,
+			Operation{Value: map[string]any{"a": []any{1.0, "b", true}}, absent: absentOp | absentFrom},
+			false,
+		},
+		{
+			"old value",
+			`{"op":"replace","path":"/a","value":1,"old":[2]}`,
+			Operation{Type: OperationReplace, Path: "/a", Value: 1.0, OldValue: []any{2.0}, absent: absentFrom, withOld: true},
+			false,
+		},
+		{
+			"null old value",
+			`{"op":"remove","path":"/a","old":null}`,
+			Operation{Type: OperationRemove, Path: "/a", absent: absentFrom | absentValue, withOld: true},
+			false,
+		},
+		{
+			"ignored old value",
+			`{"op":"add","path":"/a","value":1,"old":2}`,
+			Operation{Type: OperationAdd, Path: "/a", Value: 1.0, absent: absentFrom},
+			false,
+		},
+		{
+			"large integers",
+			`{"op":"replace","path":"/a","value":[9007199254740993,-9007199254740993,9007199254740992,1.5],"old":{"b":123456789012345678901}}`,
+			Operation{
+				Type:     OperationReplace,
+				Path:     "/a",
+				Value:    []any{json.Number("9007199254740993"), json.Number("-9007199254740993"), 9007199254740992.0, 1.5},
+				OldValue: map[string]any{"b": json.Number("123456789012345678901")},
+				absent:   absentFrom,
+				withOld:  true,
+			},
+			false,
+		},
+		{"null path", `{"op":"remove","path":null}`, Operation{}, true},
+		{"number op", `{"op":1,"path":"/a"}`, Operation{}, true},
+		{"array", `["add","/a"]`, Operation{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			op := Operation{OldValue: 1, valueLen: 1}
+			err := json.Unmarshal([]byte(tc.json), &op)
+			if tc.err {
+				if !errors.Is(err, ErrInvalidOperation) {
+					t.Errorf("got error %v, want %v", err, ErrInvalidOperation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(op, tc.want) {
+				t.Errorf("got %#v, want %#v", op, tc.want)
+			}
+		})
+	}
+}
+
+func TestPatch_UnmarshalJSON_useNumber(t *testing.T) {
+	// The option of the decoder does not apply to the
+	// values of the operations, which must not lose the
+	// precision of large integers.
+	dec := json.NewDecoder(strings.NewReader(`[{"op":"add","path":"/a","value":9007199254740993}]`))
+	dec.UseNumber()
+
+	var p Patch
+	if err := dec.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if v := p[0].Value; v != json.Number("9007199254740993") {
+		t.Errorf("got value %v (%T), want 9007199254740993", v, v)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != `[{"value":9007199254740993,"op":"add","path":"/a"}]` {
+		t.Errorf("got %s", s)
+	}
+}
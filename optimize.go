@@ -0,0 +1,181 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// Optimize returns a patch equivalent to this patch, whose
+// redundant operations are rewritten into shorter ones.
+//
+// The operations that refer to the same or nested locations
+// are merged together as described by the Compose function,
+// which makes the same assumption about the add operations.
+// Then, like the Factorize option, a value removed and added
+// at another location is moved instead, and a value added by
+// a previous operation and added again at another location
+// is copied, if the copy operation is shorter. Since a copy
+// cannot be inverted, it is never used if the patch contains
+// test operations. The patch itself is not modified.
+func (p Patch) Optimize() (Patch, error) {
+	var c composer
+
+	for i, op := range p {
+		if err := c.add(op); err != nil {
+			return nil, patchError(err, i)
+		}
+	}
+	c.factorize()
+
+	return c.patch(), nil
+}
+
+// factorize replaces the add operations of values
+// that are removed or added elsewhere by move or
+// copy operations.
+func (c *composer) factorize() {
+	invertible := slices.ContainsFunc(c.ops, func(op composedOp) bool {
+		return op.Type == OperationTest
+	})
+	for j := 0; j < len(c.ops); j++ {
+		if c.ops[j].Type != OperationAdd {
+			continue
+		}
+		if c.findMove(j) {
+			// The operation at position j was removed,
+			// or replaced by the following one.
+			j--
+			continue
+		}
+		if !invertible {
+			c.findCopy(j)
+		}
+	}
+}
+
+// findMove replaces the remove operation of the value
+// added by the operation at position j, if any, and the
+// add operation by a move operation. It returns whether
+// the operations were replaced.
+func (c *composer) findMove(j int) bool {
+	add := c.ops[j]
+
+	for i := j - 1; i >= 0; i-- {
+		rm := c.ops[i]
+		if rm.Type != OperationRemove || isPrefix(rm.path, add.path) || !c.equalValue(c.removedValue(i), add.Value) {
+			continue
+		}
+		mv := composedOp{
+			Operation: Operation{
+				Type:     OperationMove,
+				From:     rm.Path,
+				Path:     add.Path,
+				OldValue: add.Value,
+				Value:    add.Value,
+			},
+			path: add.path,
+			from: rm.path,
+		}
+		// The move operation removes the value, and adds it
+		// at its new location immediately. Either the removal
+		// is delayed, or the addition is advanced, which must
+		// not affect the operations applied in-between.
+		switch {
+		case c.independent(rm, i+1, j):
+			c.ops[j] = mv
+			c.ops = slices.Delete(c.ops, i, i+1)
+		case c.independent(add, i+1, j):
+			c.ops[i] = mv
+			c.ops = slices.Delete(c.ops, j, j+1)
+		default:
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// findCopy replaces the add operation at position j by a
+// copy operation, if the same value is set by a previous
+// operation, and is left unchanged until then.
+func (c *composer) findCopy(j int) {
+	add := c.ops[j]
+
+	b, err := json.Marshal(add.Value)
+	if err != nil {
+		return
+	}
+	add.valueLen = len(b)
+
+	for i := j - 1; i >= 0; i-- {
+		src := c.ops[i]
+		if src.Type != OperationAdd && src.Type != OperationReplace || isAppend(src.path) {
+			continue
+		}
+		cp := composedOp{
+			Operation: Operation{
+				Type:  OperationCopy,
+				From:  src.Path,
+				Path:  add.Path,
+				Value: add.Value,
+			},
+			path: add.path,
+			from: src.path,
+		}
+		if cp.jsonLength() >= add.jsonLength() {
+			return
+		}
+		// The value must not be modified until it is copied.
+		read := composedOp{Operation: Operation{Type: OperationTest}, path: src.path}
+
+		if c.equalValue(src.Value, add.Value) && c.independent(read, i+1, j) {
+			c.ops[j] = cp
+			return
+		}
+	}
+}
+
+// removedValue returns the value removed by the remove
+// operation at position i, if it is known.
+func (c *composer) removedValue(i int) any {
+	if v := c.ops[i].OldValue; v != nil {
+		return v
+	}
+	if i > 0 {
+		if prev := c.ops[i-1]; prev.Type == OperationTest && prev.Path == c.ops[i].Path {
+			return prev.Value
+		}
+	}
+	return nil
+}
+
+// independent returns whether the operation x is independent
+// of the operations in the range [i, j).
+func (c *composer) independent(x composedOp, i, j int) bool {
+	for _, op := range c.ops[i:j] {
+		if !x.independent(op) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalValue returns whether the values v1 and v2 have
+// the same JSON representation. An unknown value, which
+// is nil, is not equal to any other value.
+func (c *composer) equalValue(v1, v2 any) bool {
+	if v1 == nil || v2 == nil {
+		return false
+	}
+	n1, err := c.a.Normalize(v1)
+	if err != nil {
+		return false
+	}
+	n2, err := c.a.Normalize(v2)
+	if err != nil {
+		return false
+	}
+	return engine.Equal(n1, n2)
+}
@@ -0,0 +1,136 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPatch_Optimize(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			"repeated replaces",
+			`[{"op":"replace","path":"/a","value":1},{"op":"replace","path":"/a","value":2},{"op":"replace","path":"/a","value":3}]`,
+			`[{"op":"replace","path":"/a","value":3}]`,
+		},
+		{
+			"add then remove",
+			`[{"op":"add","path":"/a","value":1},{"op":"add","path":"/b","value":2},{"op":"remove","path":"/a"}]`,
+			`[{"op":"add","path":"/b","value":2}]`,
+		},
+		{
+			"remove then add",
+			`[{"op":"test","path":"/a","value":{"b":"c"}},{"op":"remove","path":"/a"},{"op":"add","path":"/d","value":{"b":"c"}}]`,
+			`[{"op":"test","path":"/a","value":{"b":"c"}},{"op":"move","from":"/a","path":"/d"}]`,
+		},
+		{
+			"remove then add with unknown value",
+			`[{"op":"remove","path":"/a"},{"op":"add","path":"/d","value":{"b":"c"}}]`,
+			`[{"op":"remove","path":"/a"},{"op":"add","path":"/d","value":{"b":"c"}}]`,
+		},
+		{
+			"remove then add in array",
+			`[{"op":"test","path":"/a/0","value":"foo"},{"op":"remove","path":"/a/0"},{"op":"replace","path":"/b","value":1},{"op":"add","path":"/a/2","value":"foo"}]`,
+			`[{"op":"test","path":"/a/0","value":"foo"},{"op":"replace","path":"/b","value":1},{"op":"move","from":"/a/0","path":"/a/2"}]`,
+		},
+		{
+			"remove then add into a child",
+			`[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/a"},{"op":"add","path":"/a/b","value":1}]`,
+			`[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/a"},{"op":"add","path":"/a/b","value":1}]`,
+		},
+		{
+			"remove then add in shifted array",
+			`[{"op":"test","path":"/a/1","value":"foo"},{"op":"remove","path":"/a/1"},{"op":"remove","path":"/a/0"},{"op":"add","path":"/b","value":"foo"}]`,
+			`[{"op":"test","path":"/a/1","value":"foo"},{"op":"move","from":"/a/1","path":"/b"},{"op":"remove","path":"/a/0"}]`,
+		},
+		{
+			"copy",
+			`[{"op":"add","path":"/a","value":{"long":"value"}},{"op":"add","path":"/b","value":{"long":"value"}}]`,
+			`[{"op":"add","path":"/a","value":{"long":"value"}},{"op":"copy","from":"/a","path":"/b"}]`,
+		},
+		{
+			"copy longer than add",
+			`[{"op":"add","path":"/long","value":1},{"op":"add","path":"/a","value":1}]`,
+			`[{"op":"add","path":"/long","value":1},{"op":"add","path":"/a","value":1}]`,
+		},
+		{
+			"copy of a modified value",
+			`[{"op":"add","path":"/a","value":{"long":"value"}},{"op":"replace","path":"/c","value":1},{"op":"remove","path":"/a/long"},{"op":"add","path":"/b","value":{"long":"value"}}]`,
+			`[{"op":"add","path":"/a","value":{}},{"op":"replace","path":"/c","value":1},{"op":"add","path":"/b","value":{"long":"value"}}]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var patch, want Patch
+			if err := json.Unmarshal([]byte(tc.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			s := patch.String()
+
+			got, err := patch.Optimize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("got patch:\n%s\nwant:\n%s", got.String(), want.String())
+			}
+			if patch.String() != s {
+				t.Errorf("patch was modified")
+			}
+		})
+	}
+	if _, err := (Patch{{Type: OperationAdd, Path: "a"}}).Optimize(); !errors.Is(err, ErrInvalidOperation) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidOperation)
+	}
+}
+
+func TestPatch_Optimize_compare(t *testing.T) {
+	docs := []string{
+		`{"a":{"b":[1,2,3]},"c":"foo"}`,
+		`{"a":{"b":[3,1]},"d":{"e":"foo"}}`,
+		`{"a":{"b":[1]},"c":"foo","d":{"e":"bar"}}`,
+		`{"c":{"b":[1]},"d":[{"e":"bar"}]}`,
+	}
+	for _, opts := range [][]Option{
+		nil,
+		{Invertible()},
+		{Factorize(), LCS()},
+	} {
+		var patch Patch
+		for i := 0; i+1 < len(docs); i++ {
+			p, err := CompareJSON([]byte(docs[i]), []byte(docs[i+1]), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			patch = append(patch, p...)
+		}
+		got, err := patch.Optimize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b1, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b2, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b2) >= len(b1) {
+			t.Errorf("optimized patch is not shorter: %d >= %d", len(b2), len(b1))
+		}
+		b, err := applyPatchJSON([]byte(docs[0]), got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonStringEqual(t, string(b), docs[len(docs)-1]) {
+			t.Errorf("got %s, want %s\npatch:\n%s", b, docs[len(docs)-1], got.String())
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package jsondiff
 
+import "github.com/wI2L/jsondiff/internal/engine"
+
 // An Option changes the default behavior of a Differ.
 type Option func(*Differ)
 
@@ -36,6 +38,15 @@ func Invertible() Option {
 	return func(o *Differ) { o.opts.invertible = true }
 }
 
+// OldValues enables the serialization of the values
+// replaced or removed by the replace and remove operations,
+// as the "old" member of their JSON representation, which
+// is an extension of RFC 6902. The member is decoded back
+// into the OldValue field of the operations.
+func OldValues() Option {
+	return func(o *Differ) { o.opts.oldValues = true }
+}
+
 // MarshalFunc allows to define the function/package
 // used to marshal objects to JSON.
 // The prototype of fn must match the one of the
@@ -77,15 +88,178 @@ func InPlaceCompaction() Option {
 // Ignores defines the list of values that are ignored
 // by the diff generation, represented as a list of JSON
 // Pointer strings (RFC 6901).
+// A pointer may contain wildcard segments: "*" matches
+// exactly one segment, and "**" matches any number of
+// segments, including none. The "\*" and "\**" segments
+// match a member literally named "*" or "**".
 func Ignores(ptrs ...string) Option {
 	return func(o *Differ) {
 		if len(ptrs) == 0 {
 			return
 		}
 		o.opts.ignores = make(map[string]struct{}, len(ptrs))
+		o.opts.ignoreGlobs = nil
+
 		for _, ptr := range ptrs {
-			o.opts.ignores[ptr] = struct{}{}
+			if engine.IsGlob(ptr) {
+				o.opts.ignoreGlobs = append(o.opts.ignoreGlobs, engine.SplitGlob(ptr))
+			} else {
+				o.opts.ignores[engine.LiteralPointer(ptr)] = struct{}{}
+			}
+		}
+		o.opts.hasIgnore = true
+	}
+}
+
+// IgnoreFunc defines a function that is called for each
+// value compared by the diff generation, before any operation
+// is emitted. If the function returns true, the value is
+// ignored. The path argument is the JSON Pointer string
+// (RFC 6901) of the value. The src argument is nil for an
+// added value, and tgt is nil for a removed value.
+func IgnoreFunc(fn ignoreFunc) Option {
+	return func(o *Differ) {
+		if fn == nil {
+			return
 		}
+		o.opts.ignoreFunc = fn
 		o.opts.hasIgnore = true
 	}
 }
+
+// ArrayKeys defines the arrays whose elements are objects
+// paired by the value of an identity field, rather than
+// by position. The map associates the pointers of the
+// arrays, or patterns as for Ignores, with the name of
+// the identity field.
+// Paired elements are compared recursively, and elements
+// that changed position generate move operations. Since
+// these moves depend on the removal of the unpaired
+// elements, the Factorize option does not turn the latter
+// into moves elsewhere in the document.
+// An array whose elements are not all objects with a
+// unique identity field is compared as usual.
+func ArrayKeys(keys map[string]string) Option {
+	return func(o *Differ) {
+		if len(keys) == 0 {
+			return
+		}
+		o.opts.arrayKeys = make(map[string]string, len(keys))
+		o.opts.keyGlobs = nil
+
+		// Sort the pointers so that patterns are
+		// matched in a deterministic order.
+		ptrs := make([]string, 0, len(keys))
+		for ptr := range keys {
+			ptrs = append(ptrs, ptr)
+		}
+		sortStrings(ptrs)
+
+		for _, ptr := range ptrs {
+			if engine.IsGlob(ptr) {
+				o.opts.keyGlobs = append(o.opts.keyGlobs, keyGlob{
+					pattern: engine.SplitGlob(ptr),
+					key:     keys[ptr],
+				})
+			} else {
+				o.opts.arrayKeys[engine.LiteralPointer(ptr)] = keys[ptr]
+			}
+		}
+		o.opts.hasKeys = true
+	}
+}
+
+// FloatTolerance instructs to consider two numbers equal
+// if their absolute difference is lower than or equal to
+// abs, or to rel times the largest of their magnitudes.
+// The tolerance applies to all numbers, unless it is
+// restricted to the given pointers, or patterns as for
+// Ignores.
+// The option can be used several times, in which case the
+// first tolerance that applies to a number is used.
+func FloatTolerance(abs, rel float64, ptrs ...string) Option {
+	return func(o *Differ) {
+		t := floatTolerance{
+			abs: abs,
+			rel: rel,
+		}
+		for _, ptr := range ptrs {
+			if engine.IsGlob(ptr) {
+				t.globs = append(t.globs, engine.SplitGlob(ptr))
+			} else {
+				if t.ptrs == nil {
+					t.ptrs = make(map[string]struct{}, len(ptrs))
+				}
+				t.ptrs[engine.LiteralPointer(ptr)] = struct{}{}
+			}
+		}
+		o.opts.floatTols = append(o.opts.floatTols, t)
+		o.opts.customEqual = true
+	}
+}
+
+// EqualFunc defines a function used to compare the values
+// located at path, a pointer or a pattern as for Ignores,
+// instead of the default deep equality.
+// The function is also used to compare the elements of
+// arrays with the Equivalent and LCS options, and to find
+// the values that can be moved or copied with the Factorize
+// option. The option can be used several times, in which
+// case a function registered for an exact pointer takes
+// precedence over those registered for patterns, which
+// are tried in order.
+func EqualFunc(path string, fn equalFunc) Option {
+	return func(o *Differ) {
+		if fn == nil {
+			return
+		}
+		if engine.IsGlob(path) {
+			o.opts.equalGlobs = append(o.opts.equalGlobs, equalGlob{
+				pattern: engine.SplitGlob(path),
+				fn:      fn,
+			})
+		} else {
+			if o.opts.equalFuncs == nil {
+				o.opts.equalFuncs = make(map[string]equalFunc)
+			}
+			o.opts.equalFuncs[engine.LiteralPointer(path)] = fn
+		}
+		o.opts.customEqual = true
+	}
+}
+
+// MaxDepth limits the depth of the values that are compared
+// recursively. Arrays and objects located at depth n, that is
+// n segments below the root of the documents, are replaced as
+// a whole if they differ, instead of being compared.
+// A value lower than or equal to zero means no limit.
+func MaxDepth(n int) Option {
+	return func(o *Differ) { o.opts.maxDepth = n }
+}
+
+// MaxOperations limits the number of operations of the patch.
+// When the operations generated for an array or an object
+// exceed the limit, they are replaced by a single replacement
+// of the container, which is repeated for its parents until
+// the patch fits. The limit cannot be lower than the number
+// of operations required to replace the entire document.
+// A value lower than or equal to zero means no limit.
+func MaxOperations(n int) Option {
+	return func(o *Differ) { o.opts.maxOps = n }
+}
+
+// StreamFunc instructs the Differ to pass the operations
+// to the function fn while it compares the documents, instead
+// of accumulating them in the patch, which is returned empty.
+// If fn returns an error, the comparison is interrupted, and
+// the error is returned. The operations are passed as soon as
+// they are generated, unless the Factorize, Rationalize or
+// MaxOperations options are enabled, since these may rewrite
+// previous operations, in which case they are passed once the
+// comparison completes.
+//
+// The Encode method of a PatchEncoder can be used as the
+// function, to write the operations to an output stream.
+func StreamFunc(fn streamFunc) Option {
+	return func(o *Differ) { o.opts.stream = fn }
+}
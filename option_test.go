@@ -11,9 +11,12 @@ func TestDiffer_applyOpts(t *testing.T) {
 	var (
 		marshal      = func(any) ([]byte, error) { return nil, nil }
 		unmarshal    = func([]byte, any) error { return nil }
+		ignore       = func(string, any, any) bool { return false }
+		equal        = func(any, any) bool { return true }
 		ignoredPaths = []string{
 			"/a/b/c",
 			"/x/0/y/2/z/3",
+			"/x/*/y/**",
 		}
 	)
 	d.applyOpts(
@@ -26,7 +29,17 @@ func TestDiffer_applyOpts(t *testing.T) {
 		SkipCompact(),
 		InPlaceCompaction(),
 		Ignores(ignoredPaths...),
+		IgnoreFunc(ignore),
+		FloatTolerance(1e-9, 0, "/a/b", "/x/*"),
+		EqualFunc("/a/b", equal),
+		EqualFunc("/a/*/c", equal),
+		ArrayKeys(map[string]string{
+			"/spec/containers":                "name",
+			"/spec/containers/*/volumeMounts": "mountPath",
+		}),
 		LCS(),
+		MaxDepth(3),
+		MaxOperations(10),
 	)
 	if d.opts.factorize != true {
 		t.Errorf("factorize option is not enabled")
@@ -54,12 +67,36 @@ func TestDiffer_applyOpts(t *testing.T) {
 	}
 	if d.opts.hasIgnore != true {
 		t.Errorf("differ has no ignored paths")
-	} else if len(d.opts.ignores) != len(ignoredPaths) {
-		t.Errorf("ignored paths map length mismatch input")
+	} else if len(d.opts.ignores)+len(d.opts.ignoreGlobs) != len(ignoredPaths) {
+		t.Errorf("ignored paths length mismatch input")
+	}
+	if !cmpFuncs(d.opts.ignoreFunc, ignore) {
+		t.Errorf("ignore funcs mismatch")
+	}
+	if d.opts.hasKeys != true {
+		t.Errorf("differ has no array keys")
+	} else if len(d.opts.arrayKeys) != 1 || len(d.opts.keyGlobs) != 1 {
+		t.Errorf("array keys length mismatch input")
+	}
+	if len(d.opts.floatTols) != 1 || !d.opts.customEqual {
+		t.Errorf("float tolerance option is not enabled")
+	} else if ft := d.opts.floatTols[0]; len(ft.ptrs) != 1 || len(ft.globs) != 1 {
+		t.Errorf("float tolerance pointers mismatch input")
+	}
+	if len(d.opts.equalFuncs) != 1 || len(d.opts.equalGlobs) != 1 {
+		t.Errorf("equal funcs length mismatch input")
+	} else if !cmpFuncs(d.opts.equalFuncs["/a/b"], equal) {
+		t.Errorf("equal funcs mismatch")
 	}
 	if d.opts.lcs != true {
 		t.Errorf("lcs option is not enabled")
 	}
+	if d.opts.maxDepth != 3 {
+		t.Errorf("got max depth %d, want 3", d.opts.maxDepth)
+	}
+	if d.opts.maxOps != 10 {
+		t.Errorf("got max operations %d, want 10", d.opts.maxOps)
+	}
 }
 
 func cmpFuncs(x, y any) bool {
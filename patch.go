@@ -1,8 +1,14 @@
 package jsondiff
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wI2L/jsondiff/internal/engine"
 )
 
 var (
@@ -26,11 +32,145 @@ func (e ErrTestPointer) Error() string {
 	return fmt.Sprintf("test pointer mismatch for %q operation", e.Op)
 }
 
+// A PatchError is returned by ParsePatch and Patch.Validate
+// when an operation of a patch is malformed, by the methods
+// that require a valid patch, such as Patch.Rebase, and by the
+// functions that apply the operations of a patch, such as
+// Compose, when an operation cannot be applied.
+type PatchError struct {
+	// Err is the cause of the error. It wraps either
+	// ErrInvalidOperation, a syntax error of the JSON
+	// document, or one of the errors of the jsonpatch
+	// package, such as jsonpatch.ErrPathNotFound.
+	Err error
+
+	// Index is the position of the operation in
+	// the patch, and Offset the byte offset of its
+	// beginning in the document parsed by ParsePatch,
+	// or -1.
+	Index  int
+	Offset int64
+}
+
+func (e *PatchError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("jsondiff: operation #%d: %s", e.Index, e.Err)
+	}
+	return fmt.Sprintf("jsondiff: operation #%d at offset %d: %s", e.Index, e.Offset, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// patchError returns the error reported by the functions
+// of the package when the operation #i of a patch fails.
+func patchError(err *engine.Error, i int) *PatchError {
+	return &PatchError{Err: err, Index: i, Offset: -1}
+}
+
 // Patch represents a series of JSON Patch operations.
 type Patch []Operation
 
+// ParsePatch parses the JSON document b, which must be an
+// array of operations, and validates the patch according to
+// the rules of RFC 6902 section 4, like the Validate method.
+// If an operation is malformed, the returned error is of
+// type *PatchError.
+func ParsePatch(b []byte) (Patch, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("jsondiff: %w: patch must be a JSON array", ErrInvalidOperation)
+	}
+	patch := Patch{}
+
+	for i := 0; dec.More(); i++ {
+		// The offset of the decoder may precede the
+		// separator of the operations.
+		off := dec.InputOffset()
+		off += int64(len(b[off:]) - len(bytes.TrimLeft(b[off:], " \t\r\n,")))
+
+		var op Operation
+		if err := dec.Decode(&op); err != nil {
+			return nil, &PatchError{Err: err, Index: i, Offset: off}
+		}
+		if err := op.validate(); err != nil {
+			return nil, &PatchError{Err: err, Index: i, Offset: off}
+		}
+		patch = append(patch, op)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("jsondiff: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("jsondiff: %w: unexpected data after patch", ErrInvalidOperation)
+	}
+	return patch, nil
+}
+
+// Validate returns an error of type *PatchError if an operation
+// of the patch does not follow the rules of RFC 6902 section 4:
+// the type of the operation must be known, the path and from
+// members must be valid JSON Pointers (RFC 6901), and the from
+// location of a move operation must not be a proper prefix of
+// its path. The operations decoded from JSON must also contain
+// the members required by their type.
+func (p Patch) Validate() error {
+	for i, op := range p {
+		if err := op.validate(); err != nil {
+			return &PatchError{Err: err, Index: i, Offset: -1}
+		}
+	}
+	return nil
+}
+
+func (o Operation) validate() error {
+	switch {
+	case o.absent&absentOp != 0:
+		return fmt.Errorf("%w: missing member \"op\"", ErrInvalidOperation)
+	case o.absent&absentPath != 0:
+		return fmt.Errorf("%w: missing member \"path\"", ErrInvalidOperation)
+	}
+	switch o.Type {
+	case OperationAdd, OperationReplace, OperationTest:
+		if o.absent&absentValue != 0 {
+			return fmt.Errorf("%w: missing member \"value\"", ErrInvalidOperation)
+		}
+	case OperationMove, OperationCopy:
+		if o.absent&absentFrom != 0 {
+			return fmt.Errorf("%w: missing member \"from\"", ErrInvalidOperation)
+		}
+	case OperationRemove:
+	default:
+		return fmt.Errorf("%w: unknown operation type %q", ErrInvalidOperation, o.Type)
+	}
+	if _, err := engine.ParsePointer(o.Path); err != nil {
+		return fmt.Errorf("%w: invalid path %q: %s", ErrInvalidOperation, o.Path, err)
+	}
+	if !o.hasFrom() {
+		return nil
+	}
+	if _, err := engine.ParsePointer(o.From); err != nil {
+		return fmt.Errorf("%w: invalid from %q: %s", ErrInvalidOperation, o.From, err)
+	}
+	// https://tools.ietf.org/html/rfc6902#section-4.4
+	// The "from" location MUST NOT be a proper prefix
+	// of the "path" location; i.e., a location cannot
+	// be moved into one of its children.
+	if o.Type == OperationMove && strings.HasPrefix(o.Path, o.From+"/") {
+		return fmt.Errorf("%w: cannot move %q into one of its children", ErrInvalidOperation, o.From)
+	}
+	return nil
+}
+
 // Invert returns a patch that undo the modifications
 // represented by this patch.
+//
+// The "-" token of the pointer of a value appended to an
+// array does not identify it once added, and the inverse of
+// such operations cannot be applied. Use InvertWith to invert
+// them with the document the patch applies to.
 func (p Patch) Invert() (Patch, error) {
 	newPatch := make(Patch, 0, len(p)/2)
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -101,13 +102,13 @@ func testInvert(t *testing.T, src, tgt []byte) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		ip, err := p.Invert()
+		ip, err := invertPatch(p, src)
 		if err != nil {
 			t.Fatal(err)
 		}
 		t.Logf("%s", ip.String())
 
-		src2, err := ip.apply(tgt, true)
+		src2, err := applyPatchJSON(tgt, ip)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -120,19 +121,19 @@ func testInvert(t *testing.T, src, tgt []byte) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		ip, err := p.Invert()
+		ip, err := invertPatch(p, src)
 		if err != nil {
 			t.Fatal(err)
 		}
 		t.Logf("%s", ip.String())
 
-		iip, err := ip.Invert()
+		iip, err := invertPatch(ip, tgt)
 		if err != nil {
 			t.Fatal(err)
 		}
 		t.Logf("%s", iip.String())
 
-		tgt2, err := iip.apply(src, true)
+		tgt2, err := applyPatchJSON(src, iip)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -142,6 +143,23 @@ func testInvert(t *testing.T, src, tgt []byte) {
 	})
 }
 
+// invertPatch inverts the patch, which applies to the
+// document src. The pointers of the values appended to
+// arrays do not identify them, and such patches are
+// inverted with the document.
+func invertPatch(p Patch, src []byte) (Patch, error) {
+	for _, op := range p {
+		if strings.HasSuffix(op.Path, "/-") {
+			var doc any
+			if err := json.Unmarshal(src, &doc); err != nil {
+				return nil, err
+			}
+			return p.InvertWith(doc)
+		}
+	}
+	return p.Invert()
+}
+
 func jsonBytesEqual(t *testing.T, a, b []byte) bool {
 	t.Helper()
 
@@ -157,3 +175,92 @@ func jsonBytesEqual(t *testing.T, a, b []byte) bool {
 	}
 	return deepEqual(aa, bb)
 }
+
+func TestParsePatch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		json   string
+		index  int
+		offset int64
+	}{
+		{"valid", `[{"op":"add","path":"/a","value":null},{"op":"move","from":"/a","path":"/b"},{"op":"test","path":"/b","value":1}]`, -1, 0},
+		{"empty", ` [ ] `, -1, 0},
+		{"not an array", `{"op":"add"}`, -1, -1},
+		{"trailing data", `[] []`, -1, -1},
+		{"unknown operation", `[{"op":"remove","path":"/a"}, {"op":"merge","path":"/a"}]`, 1, 30},
+		{"missing op", `[{"path":"/a"}]`, 0, 1},
+		{"missing path", "[\n\t{\"op\":\"remove\"}]", 0, 3},
+		{"missing value", `[{"op":"test","path":"/a","value":1},{"op":"test","path":"/a"}]`, 1, 37},
+		{"missing from", `[{"op":"copy","path":"/a"}]`, 0, 1},
+		{"invalid path", `[{"op":"remove","path":"a"}]`, 0, 1},
+		{"invalid escape", `[{"op":"remove","path":"/a~2"}]`, 0, 1},
+		{"invalid from", `[{"op":"copy","from":"/~","path":"/a"}]`, 0, 1},
+		{"move into child", `[{"op":"move","from":"/a","path":"/a/b"}]`, 0, 1},
+		{"invalid member", `[{"op":"add","path":1,"value":1}]`, 0, 1},
+		{"syntax error", `[{"op":"add","path":"/a",}]`, 0, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParsePatch([]byte(tc.json))
+			if tc.index < 0 && tc.offset == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p == nil {
+					t.Error("expected non-nil patch")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected non-nil error")
+			}
+			var perr *PatchError
+			if tc.index < 0 {
+				if errors.As(err, &perr) {
+					t.Errorf("unexpected error type %T", perr)
+				}
+				return
+			}
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected error of type %T, got %v", perr, err)
+			}
+			if perr.Index != tc.index || perr.Offset != tc.offset {
+				t.Errorf("got index %d and offset %d, want %d and %d", perr.Index, perr.Offset, tc.index, tc.offset)
+			}
+			if tc.name != "syntax error" && !errors.Is(err, ErrInvalidOperation) {
+				t.Errorf("expected error to wrap %v", ErrInvalidOperation)
+			}
+		})
+	}
+}
+
+func TestPatch_Validate(t *testing.T) {
+	valid := Patch{
+		{Type: OperationAdd, Path: "/a"},
+		{Type: OperationCopy, Path: "/b"},
+		{Type: OperationMove, From: "/a", Path: "/ab"},
+		{Type: OperationReplace, Path: ""},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		name  string
+		patch Patch
+		index int
+	}{
+		{"unknown operation", Patch{{Type: OperationAdd, Path: "/a"}, {Type: "merge"}}, 1},
+		{"invalid path", Patch{{Type: OperationRemove, Path: "/a~"}}, 0},
+		{"move root", Patch{{Type: OperationMove, From: "", Path: "/a"}}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.patch.Validate()
+			var perr *PatchError
+			if !errors.As(err, &perr) || !errors.Is(err, ErrInvalidOperation) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if perr.Index != tc.index || perr.Offset != -1 {
+				t.Errorf("got index %d and offset %d, want %d and -1", perr.Index, perr.Offset, tc.index)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package jsondiff
+
+import (
+	"fmt"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// Filter returns the operations of the patch whose path, and
+// from location, refer to the location of the document pointed
+// to by prefix, or to one of its descendants. The prefix is a
+// JSON Pointer (RFC 6901), which is compared with the pointers
+// of the operations token by token, once unescaped: the prefix
+// "/a" matches the path "/a/b", but not "/ab". If the prefix is
+// invalid, an error that wraps ErrInvalidOperation is returned,
+// and if the patch is invalid, the error is of type *PatchError.
+// The patch itself is not modified.
+func (p Patch) Filter(prefix string) (Patch, error) {
+	pp, ops, err := p.scope(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var res Patch
+	for i, x := range ops {
+		if x.under(pp) {
+			res = append(res, p[i])
+		}
+	}
+	return res, nil
+}
+
+// Rebase returns a copy of the patch that applies to the value
+// of a document pointed to by prefix, instead of the document
+// itself: the prefix is stripped from the path and from location
+// of each operation. All the pointers of the patch must refer to
+// the location of the prefix, or to one of its descendants, which
+// the Filter method can ensure. Otherwise, an error of type
+// *PatchError is returned, like for an invalid patch. An error
+// that wraps ErrInvalidOperation is returned if the prefix is an
+// invalid JSON Pointer. The patch itself is not modified.
+func (p Patch) Rebase(prefix string) (Patch, error) {
+	pp, ops, err := p.scope(prefix)
+	if err != nil {
+		return nil, err
+	}
+	res := make(Patch, len(p))
+	for i, x := range ops {
+		if !x.under(pp) {
+			return nil, &PatchError{
+				Err:    fmt.Errorf("%w: operation not under prefix %q", ErrInvalidOperation, prefix),
+				Index:  i,
+				Offset: -1,
+			}
+		}
+		n := len(pp.Tokens)
+		res[i] = p[i]
+		res[i].Path = engine.JoinPointer(x.path.Tokens[n:]).Str
+		if x.hasFrom() {
+			res[i].From = engine.JoinPointer(x.from.Tokens[n:]).Str
+		}
+	}
+	return res, nil
+}
+
+// Nest returns a copy of the patch that applies to a parent
+// document, whose value pointed to by prefix is the document
+// the patch applies to: the prefix is prepended to the path and
+// from location of each operation. It is the inverse of Rebase.
+// If the prefix is invalid, an error that wraps ErrInvalidOperation
+// is returned, and if the patch is invalid, the error is of type
+// *PatchError. The patch itself is not modified.
+func (p Patch) Nest(prefix string) (Patch, error) {
+	pp, ops, err := p.scope(prefix)
+	if err != nil {
+		return nil, err
+	}
+	res := make(Patch, len(p))
+	for i, x := range ops {
+		res[i] = p[i]
+		res[i].Path = engine.JoinPointer(pp.Tokens, x.path.Tokens).Str
+		if x.hasFrom() {
+			res[i].From = engine.JoinPointer(pp.Tokens, x.from.Tokens).Str
+		}
+	}
+	return res, nil
+}
+
+// scope validates the patch and parses the prefix, and
+// returns the operations with their parsed pointers.
+func (p Patch) scope(prefix string) (engine.ParsedPointer, []composedOp, error) {
+	if _, err := engine.ParsePointer(prefix); err != nil {
+		return engine.ParsedPointer{}, nil, fmt.Errorf("jsondiff: %w: invalid prefix %q: %s", ErrInvalidOperation, prefix, err)
+	}
+	pp, _ := engine.ParsePath(prefix)
+
+	if err := p.Validate(); err != nil {
+		return engine.ParsedPointer{}, nil, err
+	}
+	ops := make([]composedOp, len(p))
+	for i, op := range p {
+		ops[i] = composedOp{Operation: op}
+		ops[i].path, _ = engine.ParsePath(op.Path)
+		if op.hasFrom() {
+			ops[i].from, _ = engine.ParsePath(op.From)
+		}
+	}
+	return pp, ops, nil
+}
+
+// under returns whether the pointers of the operation
+// refer to the location p, or to its descendants.
+func (x composedOp) under(p engine.ParsedPointer) bool {
+	return isPrefix(p, x.path) && (!x.hasFrom() || isPrefix(p, x.from))
+}
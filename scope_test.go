@@ -0,0 +1,115 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPatch_scope(t *testing.T) {
+	const patch = `[
+		{"op":"replace","path":"/spec/replicas","value":3},
+		{"op":"add","path":"/metadata/labels/app.kubernetes.io~1name","value":"foo"},
+		{"op":"move","from":"/spec/a~0b","path":"/spec/c~1d"},
+		{"op":"copy","from":"/metadata/name","path":"/spec/name"},
+		{"op":"remove","path":"/spec"},
+		{"op":"test","path":"/specs","value":1}
+	]`
+	for _, tc := range []struct {
+		name   string
+		prefix string
+		filter string
+		rebase string
+	}{
+		{
+			"root",
+			"",
+			patch,
+			patch,
+		},
+		{
+			"member",
+			"/spec",
+			`[
+				{"op":"replace","path":"/spec/replicas","value":3},
+				{"op":"move","from":"/spec/a~0b","path":"/spec/c~1d"},
+				{"op":"remove","path":"/spec"}
+			]`,
+			`[
+				{"op":"replace","path":"/replicas","value":3},
+				{"op":"move","from":"/a~0b","path":"/c~1d"},
+				{"op":"remove","path":""}
+			]`,
+		},
+		{
+			"escaped member",
+			"/metadata/labels/app.kubernetes.io~1name",
+			`[{"op":"add","path":"/metadata/labels/app.kubernetes.io~1name","value":"foo"}]`,
+			`[{"op":"add","path":"","value":"foo"}]`,
+		},
+		{
+			"no match",
+			"/status",
+			`null`,
+			`null`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p, filter, rebase Patch
+			for _, v := range []struct {
+				s string
+				p *Patch
+			}{{patch, &p}, {tc.filter, &filter}, {tc.rebase, &rebase}} {
+				if err := json.Unmarshal([]byte(v.s), v.p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := p.Filter(tc.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != filter.String() {
+				t.Errorf("got filtered patch:\n%s\nwant:\n%s", got.String(), filter.String())
+			}
+			got, err = got.Rebase(tc.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != rebase.String() {
+				t.Errorf("got rebased patch:\n%s\nwant:\n%s", got.String(), rebase.String())
+			}
+			got, err = got.Nest(tc.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != filter.String() {
+				t.Errorf("got nested patch:\n%s\nwant:\n%s", got.String(), filter.String())
+			}
+		})
+	}
+}
+
+func TestPatch_scope_errors(t *testing.T) {
+	p := Patch{
+		{Type: OperationReplace, Path: "/a/b", Value: 1},
+		{Type: OperationCopy, From: "/c", Path: "/a/d"},
+	}
+	for _, f := range []func(string) (Patch, error){p.Filter, p.Rebase, p.Nest} {
+		if _, err := f("a"); !errors.Is(err, ErrInvalidOperation) {
+			t.Errorf("got error %v, want %v", err, ErrInvalidOperation)
+		}
+	}
+	var perr *PatchError
+
+	_, err := p.Rebase("/a")
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PatchError", err)
+	}
+	if perr.Index != 1 {
+		t.Errorf("got index %d, want 1", perr.Index)
+	}
+	_, err = Patch{{Type: OperationAdd, Path: "a"}}.Nest("/a")
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PatchError", err)
+	}
+}
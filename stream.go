@@ -0,0 +1,108 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var errEncoderClosed = errors.New("jsondiff: encoder is closed")
+
+// A PatchEncoder writes the operations of a patch to an
+// output stream, one at a time, without holding the patch
+// in memory. The operations are written as the elements of
+// a JSON array, or as newline-delimited JSON (NDJSON).
+type PatchEncoder struct {
+	w      io.Writer
+	buf    []byte
+	err    error
+	count  int
+	ndjson bool
+	closed bool
+}
+
+// NewPatchEncoder returns a new encoder that writes to w.
+// By default, the operations are written as a JSON array,
+// which is terminated by the Close method.
+func NewPatchEncoder(w io.Writer) *PatchEncoder {
+	return &PatchEncoder{w: w}
+}
+
+// SetNDJSON specifies whether the operations are written
+// as newline-delimited JSON, one operation per line, instead
+// of a JSON array. It must be called before the first call
+// to Encode.
+func (e *PatchEncoder) SetNDJSON(on bool) {
+	e.ndjson = on
+}
+
+// Encode writes the JSON representation of the operation
+// to the stream. Once an error has occurred, all subsequent
+// calls return the same error.
+func (e *PatchEncoder) Encode(op Operation) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return errEncoderClosed
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		e.err = err
+		return err
+	}
+	e.buf = e.buf[:0]
+	if !e.ndjson {
+		if e.count == 0 {
+			e.buf = append(e.buf, '[')
+		} else {
+			e.buf = append(e.buf, ',')
+		}
+	}
+	e.buf = append(e.buf, b...)
+	if e.ndjson {
+		e.buf = append(e.buf, '\n')
+	}
+	return e.write()
+}
+
+// EncodePatch writes all the operations of the patch
+// to the stream.
+func (e *PatchEncoder) EncodePatch(p Patch) error {
+	for _, op := range p {
+		if err := e.Encode(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close terminates the JSON array, which is empty if no
+// operation was written. Nothing is written with NDJSON.
+// It does not close the underlying writer.
+func (e *PatchEncoder) Close() error {
+	if e.err != nil || e.closed {
+		return e.err
+	}
+	e.closed = true
+
+	if e.ndjson {
+		return nil
+	}
+	e.buf = e.buf[:0]
+	if e.count == 0 {
+		e.buf = append(e.buf, '[')
+	}
+	e.buf = append(e.buf, ']')
+
+	return e.write()
+}
+
+func (e *PatchEncoder) write() error {
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.err = err
+		return err
+	}
+	e.count++
+	return nil
+}
@@ -0,0 +1,223 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestPatchEncoder(t *testing.T) {
+	patch := Patch{
+		{Type: OperationAdd, Path: "/a", Value: nil},
+		{Type: OperationMove, From: "/b", Path: "/c"},
+		{Type: OperationReplace, Path: "/d", Value: 1, OldValue: 0, withOld: true},
+	}
+	for _, tc := range []struct {
+		name   string
+		ndjson bool
+		patch  Patch
+		want   string
+	}{
+		{
+			"array",
+			false,
+			patch,
+			`[{"value":null,"op":"add","path":"/a"},{"op":"move","from":"/b","path":"/c"},{"value":1,"op":"replace","path":"/d","old":0}]`,
+		},
+		{
+			"empty array",
+			false,
+			nil,
+			`[]`,
+		},
+		{
+			"ndjson",
+			true,
+			patch,
+			`{"value":null,"op":"add","path":"/a"}` + "\n" +
+				`{"op":"move","from":"/b","path":"/c"}` + "\n" +
+				`{"value":1,"op":"replace","path":"/d","old":0}` + "\n",
+		},
+		{
+			"empty ndjson",
+			true,
+			nil,
+			``,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			enc := NewPatchEncoder(&buf)
+			enc.SetNDJSON(tc.ndjson)
+
+			if err := enc.EncodePatch(tc.patch); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if s := buf.String(); s != tc.want {
+				t.Errorf("got %q, want %q", s, tc.want)
+			}
+			if !tc.ndjson {
+				var p Patch
+				if err := json.Unmarshal(buf.Bytes(), &p); err != nil {
+					t.Fatal(err)
+				}
+				if p.String() != tc.patch.String() {
+					t.Errorf("got patch:\n%s\nwant:\n%s", p.String(), tc.patch.String())
+				}
+			}
+			if err := enc.Encode(Operation{Type: OperationRemove, Path: "/a"}); err == nil {
+				t.Error("expected non-nil error after close")
+			}
+		})
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("write failed")
+	}
+	w.n--
+	return len(b), nil
+}
+
+func TestPatchEncoder_errors(t *testing.T) {
+	enc := NewPatchEncoder(&failingWriter{n: 1})
+
+	op := Operation{Type: OperationRemove, Path: "/a"}
+	if err := enc.Encode(op); err != nil {
+		t.Fatal(err)
+	}
+	err := enc.Encode(op)
+	if err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	// The error is sticky.
+	if err2 := enc.Encode(op); err2 != err {
+		t.Errorf("got error %v, want %v", err2, err)
+	}
+	if err2 := enc.Close(); err2 != err {
+		t.Errorf("got error %v, want %v", err2, err)
+	}
+	enc = NewPatchEncoder(&bytes.Buffer{})
+	if err := enc.Encode(Operation{Type: OperationAdd, Path: "/a", Value: make(chan int)}); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestStreamFunc(t *testing.T) {
+	makeOpts := func(opts ...Option) []Option { return opts }
+
+	for _, tc := range []struct {
+		testFile string
+		options  []Option
+	}{
+		{"testdata/tests/jsonpatch/array.json", makeOpts()},
+		{"testdata/tests/jsonpatch/object.json", makeOpts()},
+		{"testdata/tests/jsonpatch/root.json", makeOpts()},
+		{"testdata/tests/jsonpatch/options/invertible.json", makeOpts(Invertible())},
+		{"testdata/tests/jsonpatch/options/factorization.json", makeOpts(Factorize())},
+		{"testdata/tests/jsonpatch/options/rationalization.json", makeOpts(Rationalize())},
+		{"testdata/tests/jsonpatch/options/lcs.json", makeOpts(LCS(), Factorize())},
+		{"testdata/tests/jsonpatch/options/all.json", makeOpts(Factorize(), Rationalize(), Invertible(), Equivalent())},
+		{"testdata/tests/jsonpatch/options/lcs+equivalence.json", makeOpts(LCS(), Equivalent())},
+		{"testdata/tests/jsonpatch/options/old-values.json", makeOpts(OldValues())},
+		{"testdata/tests/jsonpatch/options/keys.json", makeOpts(ArrayKeys(map[string]string{
+			"/items":            "name",
+			"/groups/*/members": "id",
+		}))},
+		{"testdata/tests/jsonpatch/options/keys+factorization.json", makeOpts(Factorize(), ArrayKeys(map[string]string{
+			"/items":            "name",
+			"/groups/*/members": "id",
+		}))},
+	} {
+		b, err := os.ReadFile(tc.testFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cases []testcase
+		if err := json.Unmarshal(b, &cases); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range cases {
+			want, err := Compare(c.Before, c.After, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Patch
+			opts := append(tc.options, StreamFunc(func(op Operation) error { //nolint:gocritic
+				got = append(got, op)
+				return nil
+			}))
+			patch, err := Compare(c.Before, c.After, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patch) != 0 {
+				t.Errorf("%s: %s: got %d operations in the returned patch, want none", tc.testFile, c.Name, len(patch))
+			}
+			if got.String() != want.String() {
+				t.Errorf("%s: %s: got patch:\n%s\nwant:\n%s", tc.testFile, c.Name, got.String(), want.String())
+			}
+		}
+	}
+}
+
+func TestStreamFunc_incremental(t *testing.T) {
+	src := map[string]any{"a": []any{}}
+	tgt := map[string]any{"a": []any{}}
+	for i := 0; i < 100; i++ {
+		src[fmt.Sprint("k", i)] = i
+		tgt["a"] = append(tgt["a"].([]any), float64(i))
+	}
+	var (
+		d Differ
+		n int
+	)
+	d.WithOpts(Invertible(), StreamFunc(func(op Operation) error {
+		n++
+		// A removal is preceded by a test operation.
+		if l := len(d.Patch()); l > 2 {
+			t.Fatalf("got %d pending operations, want at most 2", l)
+		}
+		return nil
+	}))
+	d.Compare(src, tgt)
+
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 300 {
+		t.Errorf("got %d operations, want 300", n)
+	}
+}
+
+func TestStreamFunc_error(t *testing.T) {
+	errStop := errors.New("stop")
+
+	for _, opts := range [][]Option{nil, {Factorize()}} {
+		var n int
+		opts = append(opts, StreamFunc(func(op Operation) error {
+			n++
+			return errStop
+		}))
+		patch, err := CompareJSON([]byte(`{"a":1,"b":2,"c":3}`), []byte(`{}`), opts...)
+		if !errors.Is(err, errStop) {
+			t.Errorf("got error %v, want %v", err, errStop)
+		}
+		if patch != nil {
+			t.Errorf("expected nil patch, got %s", patch.String())
+		}
+		if n != 1 {
+			t.Errorf("got %d calls, want 1", n)
+		}
+	}
+}
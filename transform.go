@@ -0,0 +1,494 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/wI2L/jsondiff/internal/engine"
+)
+
+// ErrAmbiguousTransform is returned by Transform when the
+// result of two concurrent operations cannot be determined
+// without the document they apply to.
+var ErrAmbiguousTransform = errors.New("ambiguous concurrent operations")
+
+// Transform transforms the patches a and b, which were both
+// generated against the same document, into the patches a'
+// and b', such that applying a then b' to the document gives
+// the same result as applying b then a'.
+//
+// The locations referenced by the operations of each patch are
+// rewritten to account for the changes of the other patch: the
+// array indices shifted by the insertions and deletions of the
+// other side are adjusted, and the operations follow the values
+// that are moved. An operation whose location is removed or
+// replaced by the other side, or one of its ancestors, is dropped,
+// except for the move operations, whose value is removed. When
+// both patches change the same location, the operation of a wins,
+// and the insertions of a in an array are placed before the ones
+// of b at the same index. A test operation is kept only if the
+// other side does not change the value it checks.
+//
+// The document being unknown, a token of a pointer that is an
+// array index, or "-", is assumed to refer to an array element.
+// Like the patches generated by the Compare functions, an add,
+// move or copy operation whose path refers to an object member
+// is assumed to create that member. When the result cannot be
+// determined, for instance if both patches append a value to the
+// same array with the "-" token, or if a value copied by one side
+// is changed by the other, an error that wraps ErrAmbiguousTransform
+// is returned. If a patch is invalid, the error is of type
+// *PatchError.
+func Transform(a, b Patch) (Patch, Patch, error) {
+	if err := a.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := b.Validate(); err != nil {
+		return nil, nil, err
+	}
+	ta, tb, err := transformOps(parseOps(a), parseOps(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	return formatOps(ta), formatOps(tb), nil
+}
+
+// parseOps returns the operations of a valid patch along
+// with their parsed pointers. The moves of a value to its
+// own location have no effect, and are dropped.
+func parseOps(p Patch) []composedOp {
+	ops := make([]composedOp, 0, len(p))
+	for _, op := range p {
+		if op.Type == OperationMove && op.From == op.Path {
+			continue
+		}
+		x := composedOp{Operation: op}
+		x.path, _ = engine.ParsePath(op.Path)
+		if op.hasFrom() {
+			x.from, _ = engine.ParsePath(op.From)
+		}
+		ops = append(ops, x)
+	}
+	return ops
+}
+
+func formatOps(ops []composedOp) Patch {
+	if len(ops) == 0 {
+		return nil
+	}
+	p := make(Patch, len(ops))
+	for i, op := range ops {
+		p[i] = op.Operation
+		p[i].Path = op.path.Str
+		if op.hasFrom() {
+			p[i].From = op.from.Str
+		}
+	}
+	return p
+}
+
+// transformOps transforms the lists of concurrent operations
+// a and b, such that a followed by b' is equivalent to b
+// followed by a'. The operations of a win the conflicts.
+func transformOps(a, b []composedOp) ([]composedOp, []composedOp, error) {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return a, b, nil
+	case len(a) == 1 && len(b) == 1:
+		ta, err := a[0].transform(b[0], true)
+		if err != nil {
+			return nil, nil, err
+		}
+		tb, err := b[0].transform(a[0], false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ta, tb, nil
+	case len(a) > 1:
+		// The first operation of a is transformed against
+		// the operations of b, which are then transformed
+		// against the remaining operations of a.
+		ta1, tb1, err := transformOps(a[:1], b)
+		if err != nil {
+			return nil, nil, err
+		}
+		ta2, tb2, err := transformOps(a[1:], tb1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return slices.Concat(ta1, ta2), tb2, nil
+	default:
+		ta1, tb1, err := transformOps(a, b[:1])
+		if err != nil {
+			return nil, nil, err
+		}
+		ta2, tb2, err := transformOps(ta1, b[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		return ta2, slices.Concat(tb1, tb2), nil
+	}
+}
+
+// The roles of a pointer in an operation.
+const (
+	roleTarget = iota // existing location
+	roleInsert        // array insertion
+	roleSet           // object member creation
+)
+
+// The states of a pointer transformed by an
+// operation, by increasing order of severity.
+const (
+	ptrKept      = iota // unchanged, or shifted
+	ptrMoved            // moved along with its value
+	ptrReplaced         // the location was replaced
+	ptrRemoved          // the location was removed
+	ptrDestroyed        // an ancestor was removed or replaced
+)
+
+// transform returns the operations that apply the changes of the
+// operation y after the concurrent operation x was applied. If win
+// is true, y wins the conflicts with x.
+func (y composedOp) transform(x composedOp, win bool) ([]composedOp, error) {
+	if x.Type == OperationTest {
+		return []composedOp{y}, nil
+	}
+	if x.Type == OperationCopy && y.writesRelated(x.from) || y.Type == OperationCopy && x.writesRelated(y.from) {
+		return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+	}
+	switch y.Type {
+	case OperationTest:
+		if x.writesRelated(y.path) {
+			return nil, nil
+		}
+		p, _, err := x.transformPointer(y.path, roleTarget, win)
+		if err != nil {
+			return nil, err
+		}
+		y.path = p
+		return []composedOp{y}, nil
+	case OperationMove:
+		return y.transformMove(x, win)
+	}
+	role := y.pathRole()
+
+	p, st, err := x.transformPointer(y.path, role, win)
+	if err != nil {
+		return nil, err
+	}
+	orig := y.path
+	y.path = p
+
+	switch st {
+	case ptrMoved:
+		if role == roleSet && isIndex(p) {
+			// The member set by y was moved to an array, where
+			// it must be replaced instead of inserted.
+			if y.Type == OperationCopy {
+				return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+			}
+			y.Type = OperationReplace
+		}
+	case ptrDestroyed:
+		return nil, nil
+	case ptrReplaced:
+		if !win {
+			return nil, nil
+		}
+	case ptrRemoved:
+		switch {
+		case !win || y.Type == OperationRemove:
+			return nil, nil
+		case y.Type == OperationReplace:
+			// Restore the removed location.
+			y.Type = OperationAdd
+		}
+	}
+	if y.Type == OperationCopy {
+		if y.from, _, err = x.transformPointer(y.from, roleTarget, win); err != nil {
+			return nil, err
+		}
+		return []composedOp{y}, nil
+	}
+	return y.removeMoved(x, orig, x.from, win)
+}
+
+// removeMoved returns the operation y, followed by the removal
+// of the value moved by the operation x from the location from,
+// if y overwrites the location orig, that is one of its ancestors,
+// like if x was applied before y.
+func (y composedOp) removeMoved(x composedOp, orig, from engine.ParsedPointer, win bool) ([]composedOp, error) {
+	ops := []composedOp{y}
+
+	if x.Type != OperationMove || !isPrefix(orig, from) || len(orig.Tokens) == len(from.Tokens) {
+		return ops, nil
+	}
+	// The value moved by x into the location overwritten by y
+	// is overwritten too, unless it is moved along by y.
+	if isPrefix(y.path, x.path) && (y.Type != OperationMove || !isPrefix(y.from, x.path)) {
+		return ops, nil
+	}
+	if !y.overwrites() && (y.Type != OperationMove || isIndex(y.path)) {
+		return ops, nil
+	}
+	if isAppend(x.path) {
+		return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+	}
+	rm := composedOp{Operation: Operation{Type: OperationRemove}}
+
+	var err error
+	if rm.path, _, err = y.transformPointer(x.path, roleTarget, win); err != nil {
+		return nil, err
+	}
+	return append(ops, rm), nil
+}
+
+// transformMove is like transform, for a move operation y.
+func (y composedOp) transformMove(x composedOp, win bool) ([]composedOp, error) {
+	from, fst, err := x.transformPointer(y.from, roleTarget, win)
+	if err != nil {
+		return nil, err
+	}
+	// The path of y refers to the document from which the value
+	// was removed, hence the changes of x are transformed past
+	// that removal first.
+	rm := composedOp{Operation: Operation{Type: OperationRemove}, path: y.from}
+	if x.Type == OperationMove && fst == ptrReplaced {
+		if src, _ := remove(y.from, x.from, roleTarget); isPrefix(src, y.path) {
+			// The value moved by x to the source of y would
+			// be moved by y into one of its children.
+			return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+		}
+	}
+	xr := x
+	if x.Type == OperationMove && isPrefix(y.from, x.from) && len(x.from.Tokens) > len(y.from.Tokens) {
+		// Only the value added by x is left.
+		xr = composedOp{Operation: Operation{Type: OperationAdd}, path: x.path}
+	}
+	xs, err := xr.transform(rm, false)
+	if err != nil {
+		return nil, err
+	}
+	path, pst := y.path, ptrKept
+	for _, op := range xs {
+		var st int
+		if path, st, err = op.transformPointer(path, y.pathRole(), win); err != nil {
+			return nil, err
+		}
+		pst = max(pst, st)
+	}
+	if pst == ptrMoved && y.pathRole() == roleSet && isIndex(path) {
+		return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+	}
+	if fst == ptrDestroyed || fst == ptrRemoved || fst == ptrMoved && !win && x.Type == OperationMove && slices.Equal(x.from.Tokens, y.from.Tokens) {
+		// The value was removed, or moved elsewhere by x. If
+		// y replaced an object member, it is removed as well.
+		if isPrefix(y.path, y.from) {
+			// The replaced value is an ancestor of the
+			// value, whose changes cannot be undone.
+			return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+		}
+		if !y.replaces || pst != ptrKept && pst != ptrMoved {
+			return nil, nil
+		}
+		return []composedOp{{Operation: Operation{Type: OperationRemove}, path: path}}, nil
+	}
+	orig := y.path
+	y.from = from
+	y.path = path
+
+	switch {
+	case pst == ptrDestroyed || (pst == ptrReplaced || pst == ptrRemoved) && !win:
+		// The value cannot be moved, but it is
+		// still removed from its location.
+		y.Type = OperationRemove
+		y.path = from
+		y.from = engine.ParsedPointer{}
+		y.From = emptyPointer
+		y.Value = nil
+		y.replaces = false
+	case pst == ptrReplaced:
+		y.replaces = true
+	case pst == ptrRemoved:
+		y.replaces = false
+	}
+	switch {
+	case y.Type != OperationMove:
+		return []composedOp{y}, nil
+	case slices.Equal(y.from.Tokens, y.path.Tokens):
+		// The value is moved to its own location.
+		return nil, nil
+	}
+	ops := []composedOp{y}
+
+	if !isPrefix(rm.path, x.from) {
+		// The source of x is compared to the path of y
+		// once the value moved by y is removed. A value
+		// moved by x out of the value of y is left as is.
+		src, _ := remove(rm.path, x.from, roleTarget)
+
+		if ops, err = y.removeMoved(x, orig, src, win); err != nil {
+			return nil, err
+		}
+	}
+	if isPrefix(y.from, y.path) {
+		// The value would be moved into the element that
+		// follows it in the array, which cannot be expressed
+		// with a single operation.
+		return nil, fmt.Errorf("jsondiff: %w: %s and %s", ErrAmbiguousTransform, y, x)
+	}
+	return ops, nil
+}
+
+// pathRole returns the role of the path of the operation.
+func (y composedOp) pathRole() int {
+	switch y.Type {
+	case OperationAdd, OperationMove, OperationCopy:
+		if isIndex(y.path) {
+			return roleInsert
+		}
+		return roleSet
+	}
+	return roleTarget
+}
+
+// writesRelated returns whether the operation changes
+// the location p, one of its ancestors, or descendants.
+func (y composedOp) writesRelated(p engine.ParsedPointer) bool {
+	related := func(q, p engine.ParsedPointer) bool {
+		return isPrefix(q, p) || isPrefix(p, q)
+	}
+	switch y.Type {
+	case OperationAdd, OperationRemove, OperationReplace, OperationCopy:
+		return related(y.path, p)
+	case OperationMove:
+		if related(y.from, p) {
+			return true
+		}
+		// The path of a move refers to the document
+		// from which the value was removed.
+		q, _, _ := y.transformPointer(p, roleTarget, false)
+		return related(y.path, q)
+	}
+	return false
+}
+
+// transformPointer returns the pointer p, whose role is r in a
+// concurrent operation, rewritten to account for the changes of
+// the operation x, and its state.
+func (x composedOp) transformPointer(p engine.ParsedPointer, r int, win bool) (engine.ParsedPointer, int, error) {
+	switch x.Type {
+	case OperationAdd, OperationCopy:
+		if isIndex(x.path) {
+			return shiftInsert(x.path, p, r, win)
+		}
+		p, st := overwrite(x.path, p, r)
+		return p, st, nil
+	case OperationReplace:
+		p, st := overwrite(x.path, p, r)
+		return p, st, nil
+	case OperationRemove:
+		p, st := remove(x.path, p, r)
+		return p, st, nil
+	case OperationMove:
+		if isPrefix(x.from, p) && (r != roleInsert || len(p.Tokens) > len(x.from.Tokens)) {
+			// The value is moved to the path of x.
+			if isAppend(x.path) {
+				return engine.ParsedPointer{}, 0, fmt.Errorf("jsondiff: %w: %q and %s", ErrAmbiguousTransform, p.Str, x)
+			}
+			return engine.JoinPointer(x.path.Tokens, p.Tokens[len(x.from.Tokens):]), ptrMoved, nil
+		}
+		p, _ = remove(x.from, p, r)
+
+		if isIndex(x.path) {
+			return shiftInsert(x.path, p, r, win)
+		}
+		p, st := overwrite(x.path, p, r)
+		return p, st, nil
+	}
+	return p, ptrKept, nil
+}
+
+// shiftInsert transforms the pointer p for the insertion
+// of an array element at the location q.
+func shiftInsert(q, p engine.ParsedPointer, r int, win bool) (engine.ParsedPointer, int, error) {
+	n := len(q.Tokens) - 1
+	if len(p.Tokens) <= n || !slices.Equal(q.Tokens[:n], p.Tokens[:n]) {
+		return p, ptrKept, nil
+	}
+	k, i := q.Tokens[n], p.Tokens[n]
+	if k == "-" {
+		if i == "-" && r == roleInsert && len(p.Tokens) == n+1 {
+			// The order of the values appended by both
+			// sides depends on the order of application,
+			// unless the length of the array is known.
+			return engine.ParsedPointer{}, 0, fmt.Errorf("jsondiff: %w: concurrent insertions at %q", ErrAmbiguousTransform, p.Str)
+		}
+		return p, ptrKept, nil
+	}
+	ki, _ := engine.ParseIndex(k)
+	ii, ok := engine.ParseIndex(i)
+	if !ok || ii < ki {
+		return p, ptrKept, nil
+	}
+	if ii == ki && r == roleInsert && len(p.Tokens) == n+1 && win {
+		// The insertion of y precedes the one of x.
+		return p, ptrKept, nil
+	}
+	return withIndex(p, n, ii+1), ptrKept, nil
+}
+
+// remove transforms the pointer p for the
+// removal of the value at the location q.
+func remove(q, p engine.ParsedPointer, r int) (engine.ParsedPointer, int) {
+	if !isPrefix(q, p) {
+		if !isIndex(q) {
+			return p, ptrKept
+		}
+		// Shift the following array elements.
+		n := len(q.Tokens) - 1
+		if len(p.Tokens) <= n || !slices.Equal(q.Tokens[:n], p.Tokens[:n]) {
+			return p, ptrKept
+		}
+		ki, _ := engine.ParseIndex(q.Tokens[n])
+		ii, ok := engine.ParseIndex(p.Tokens[n])
+		if !ok || ii < ki {
+			return p, ptrKept
+		}
+		return withIndex(p, n, ii-1), ptrKept
+	}
+	switch {
+	case len(p.Tokens) > len(q.Tokens):
+		return p, ptrDestroyed
+	case r == roleInsert:
+		// Insertion at the position of the removed element.
+		return p, ptrKept
+	}
+	return p, ptrRemoved
+}
+
+// overwrite transforms the pointer p for the
+// replacement of the value at the location q.
+func overwrite(q, p engine.ParsedPointer, r int) (engine.ParsedPointer, int) {
+	switch {
+	case !isPrefix(q, p):
+		return p, ptrKept
+	case len(p.Tokens) > len(q.Tokens):
+		return p, ptrDestroyed
+	case r == roleInsert:
+		// Insertion before the replaced element.
+		return p, ptrKept
+	}
+	return p, ptrReplaced
+}
+
+// withIndex returns a copy of the pointer whose
+// token at position n is the array index idx.
+func withIndex(p engine.ParsedPointer, n, idx int) engine.ParsedPointer {
+	tokens := slices.Clone(p.Tokens)
+	tokens[n] = strconv.Itoa(idx)
+	return engine.JoinPointer(tokens)
+}
@@ -0,0 +1,215 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		base string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			"distinct members",
+			`{"a":1,"b":2}`,
+			`[{"op":"replace","path":"/a","value":10}]`,
+			`[{"op":"remove","path":"/b"},{"op":"add","path":"/c","value":3}]`,
+			`{"a":10,"c":3}`,
+		},
+		{
+			"shifted indices",
+			`{"a":[1,2,3,4]}`,
+			`[{"op":"add","path":"/a/0","value":0},{"op":"remove","path":"/a/3"}]`,
+			`[{"op":"replace","path":"/a/2","value":30},{"op":"remove","path":"/a/1"},{"op":"add","path":"/a/3","value":5}]`,
+			`{"a":[0,1,4,5]}`,
+		},
+		{
+			"insertions at the same index",
+			`{"a":[1,2]}`,
+			`[{"op":"add","path":"/a/1","value":"a"}]`,
+			`[{"op":"add","path":"/a/1","value":"b"}]`,
+			`{"a":[1,"a","b",2]}`,
+		},
+		{
+			"same member",
+			`{"a":1}`,
+			`[{"op":"replace","path":"/a","value":2}]`,
+			`[{"op":"replace","path":"/a","value":3}]`,
+			`{"a":2}`,
+		},
+		{
+			"removed and replaced",
+			`{"a":{"b":1},"c":[1,2]}`,
+			`[{"op":"remove","path":"/a"},{"op":"replace","path":"/c/1","value":3}]`,
+			`[{"op":"replace","path":"/a/b","value":2},{"op":"remove","path":"/c/1"}]`,
+			`{"c":[1,3]}`,
+		},
+		{
+			"edit of a moved value",
+			`{"a":[{"b":1},{"b":2}],"c":{}}`,
+			`[{"op":"move","from":"/a/0","path":"/c/d"}]`,
+			`[{"op":"replace","path":"/a/0/b","value":10},{"op":"replace","path":"/a/1/b","value":20}]`,
+			`{"a":[{"b":20}],"c":{"d":{"b":10}}}`,
+		},
+		{
+			"move from a removed location",
+			`{"a":{"b":1}}`,
+			`[{"op":"remove","path":"/a"}]`,
+			`[{"op":"move","from":"/a/b","path":"/c"}]`,
+			`{}`,
+		},
+		{
+			"tests",
+			`{"a":1,"b":[1,2]}`,
+			`[{"op":"test","path":"/b/1","value":2},{"op":"replace","path":"/a","value":2}]`,
+			`[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/b/0"}]`,
+			`{"a":2,"b":[2]}`,
+		},
+		{
+			"escaped pointers",
+			`{"a/b":[1],"c~d":{"e":1}}`,
+			`[{"op":"move","from":"/c~0d","path":"/a~1b/0"}]`,
+			`[{"op":"replace","path":"/c~0d/e","value":2},{"op":"add","path":"/a~1b/0","value":0}]`,
+			`{"a/b":[{"e":2},0,1]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				base any
+				a, b Patch
+			)
+			if err := json.Unmarshal([]byte(tc.base), &base); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []struct {
+				s string
+				p *Patch
+			}{{tc.a, &a}, {tc.b, &b}} {
+				if err := json.Unmarshal([]byte(v.s), v.p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ta, tb, err := Transform(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, seq := range [][2]Patch{{a, tb}, {b, ta}} {
+				doc, err := applyPatch(base, seq[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				doc, err = applyPatch(doc, seq[1])
+				if err != nil {
+					t.Fatalf("transformed patch:\n%s\nfailed: %s", seq[1].String(), err)
+				}
+				if s := mustMarshal(t, doc); !jsonStringEqual(t, s, tc.want) {
+					t.Errorf("got %s, want %s", s, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransform_appends(t *testing.T) {
+	base := map[string]any{"tags": []any{"a"}, "ids": []any{1.0}, "n": 1.0}
+
+	for _, tc := range []struct {
+		name   string
+		a, b   any
+		want   string
+		errors bool
+	}{
+		{
+			"different arrays",
+			map[string]any{"tags": []any{"a", "b", "c"}, "ids": []any{1.0}, "n": 1.0},
+			map[string]any{"tags": []any{"a"}, "ids": []any{1.0, 2.0}, "n": 2.0},
+			`{"tags":["a","b","c"],"ids":[1,2],"n":2}`,
+			false,
+		},
+		{
+			"append and replacement",
+			map[string]any{"tags": []any{"a", "b"}, "ids": []any{1.0}, "n": 1.0},
+			map[string]any{"tags": []any{"c"}, "ids": []any{}, "n": 1.0},
+			`{"tags":["c","b"],"ids":[],"n":1}`,
+			false,
+		},
+		{
+			"same array",
+			map[string]any{"tags": []any{"a", "b", "c"}, "ids": []any{1.0}, "n": 1.0},
+			map[string]any{"tags": []any{"a", "d"}, "ids": []any{1.0}, "n": 2.0},
+			``,
+			true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := Compare(base, tc.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Compare(base, tc.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ta, tb, err := Transform(a, b)
+			if tc.errors {
+				if !errors.Is(err, ErrAmbiguousTransform) {
+					t.Fatalf("got error %v, want %v", err, ErrAmbiguousTransform)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Both orders of application converge.
+			for _, seq := range [][2]Patch{{a, tb}, {b, ta}} {
+				doc, err := applyPatch(base, seq[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				doc, err = applyPatch(doc, seq[1])
+				if err != nil {
+					t.Fatalf("transformed patch:\n%s\nfailed: %s", seq[1].String(), err)
+				}
+				if s := mustMarshal(t, doc); !jsonStringEqual(t, s, tc.want) {
+					t.Errorf("got %s, want %s", s, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransform_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a    Patch
+		b    Patch
+	}{
+		{
+			"concurrent appends",
+			Patch{{Type: OperationAdd, Path: "/a/-", Value: 1}},
+			Patch{{Type: OperationAdd, Path: "/a/-", Value: 2}},
+		},
+		{
+			"changed copy source",
+			Patch{{Type: OperationCopy, From: "/a", Path: "/b"}},
+			Patch{{Type: OperationReplace, Path: "/a/c", Value: 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Transform(tc.a, tc.b)
+			if !errors.Is(err, ErrAmbiguousTransform) {
+				t.Errorf("got error %v, want %v", err, ErrAmbiguousTransform)
+			}
+		})
+	}
+	_, _, err := Transform(nil, Patch{{Type: OperationMove, Path: "/a"}})
+	var perr *PatchError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PatchError", err)
+	}
+}